@@ -1,7 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"image/color"
+	"level"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
@@ -25,13 +27,48 @@ func (g *Game) Draw(screen *ebiten.Image) {
 
 	vector.DrawFilledCircle(screen, (pStartPos.X + g.camera.X), (pStartPos.Y + g.camera.Y), lineWidth*4, colornames.Green, true)
 
+	drawSectors(g, screen)
+
 	// Draw each vector with respect to the camera position
 	for _, vec := range walls {
 		x1 := vec.X1 + g.camera.X
 		y1 := vec.Y1 + g.camera.Y
 		x2 := vec.X2 + g.camera.X
 		y2 := vec.Y2 + g.camera.Y
-		vector.StrokeLine(screen, (x1), (y1), (x2), (y2), lineWidth, color.White, true)
+
+		// Two-sided and masked walls can be seen through
+		wallColor := color.Color(color.White)
+		if vec.Attrs.Masked {
+			wallColor = colornames.Plum
+		} else if vec.Attrs.TwoSided {
+			wallColor = colornames.Lightskyblue
+		}
+		vector.StrokeLine(screen, (x1), (y1), (x2), (y2), lineWidth, wallColor, true)
+	}
+
+	drawIssues(g, screen)
+
+	for _, d := range doors {
+		wall := d.Wall
+		x1, y1 := wall.X1+g.camera.X, wall.Y1+g.camera.Y
+		vector.StrokeLine(screen, x1, y1, wall.X2+g.camera.X, wall.Y2+g.camera.Y, lineWidth, colornames.Orange, true)
+
+		// Swinging doors show their hinge
+		label := "slide"
+		if d.Motion == level.Swing {
+			label = "swing"
+			vector.DrawFilledCircle(screen, x1, y1, lineWidth*2, colornames.Orange, true)
+		}
+		if d.Key != "" {
+			label += " " + d.Key
+		}
+		ebitenutil.DebugPrintAt(screen, label, int((x1+wall.X2+g.camera.X)/2)+4, int((y1+wall.Y2+g.camera.Y)/2)+4)
+	}
+
+	for _, spr := range sprites {
+		x, y := spr.Pos.X+g.camera.X, spr.Pos.Y+g.camera.Y
+		vector.StrokeCircle(screen, x, y, lineWidth*3, 1, colornames.Yellowgreen, true)
+		ebitenutil.DebugPrintAt(screen, spr.Texture, int(x)+8, int(y)-8)
 	}
 
 	if g.createMode {
@@ -50,8 +87,54 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	// Draw text for clarity
 	if g.createMode {
 		ebitenutil.DebugPrint(screen, "Vector created, click again to specify vector end.")
+	} else if g.sectorMode {
+		ebitenutil.DebugPrint(screen, "Click to place sector corners, enter to finish, 's' to cancel.")
 	} else {
-		ebitenutil.DebugPrint(screen, "Press 'c' to create a vector. Hold right click to move camera. p = player start")
+		ebitenutil.DebugPrint(screen, "Press 'c' to create a vector. Hold right click to move camera. p = player start\n"+
+			"s = draw sector, f = fill enclosed area as a sector, t = toggle two-sided wall, m = masked/passable wall\n"+
+			"o = place sprite, g = sliding/swinging door, pgup/pgdn = sector floor, home/end = sector ceiling")
+	}
+}
+
+// Draw sector outlines with their heights, and the sector being drawn
+func drawSectors(g *Game, screen *ebiten.Image) {
+	for _, sec := range sectors {
+		drawPolygon(g, screen, sec.Points)
+		for _, hole := range sec.Holes {
+			drawPolygon(g, screen, hole)
+		}
+		first := sec.Points[0]
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("%.2f-%.2f", sec.Floor, sec.Ceiling), int(first.X+g.camera.X)+4, int(first.Y+g.camera.Y)+4)
+	}
+
+	if !g.sectorMode {
+		return
+	}
+	mouseX, mouseY := ebiten.CursorPosition()
+	cursor := snapPos(pos32{X: float32(mouseX) - g.camera.X, Y: float32(mouseY) - g.camera.Y}, walls, lineSnapDist)
+	points := append(g.sectorPoints[:len(g.sectorPoints):len(g.sectorPoints)], cursor)
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		vector.StrokeLine(screen, a.X+g.camera.X, a.Y+g.camera.Y, b.X+g.camera.X, b.Y+g.camera.Y, lineWidth, colornames.Orange, true)
+	}
+	vector.DrawFilledCircle(screen, cursor.X+g.camera.X, cursor.Y+g.camera.Y, lineWidth*2, colornames.Orange, true)
+}
+
+func drawPolygon(g *Game, screen *ebiten.Image, points []pos32) {
+	for i, a := range points {
+		b := points[(i+1)%len(points)]
+		vector.StrokeLine(screen, a.X+g.camera.X, a.Y+g.camera.Y, b.X+g.camera.X, b.Y+g.camera.Y, 1, colornames.Orange, true)
+	}
+}
+
+// Mark the walls that don't close a loop, and where they're open
+func drawIssues(g *Game, screen *ebiten.Image) {
+	for _, issue := range issues {
+		if issue.Wall < len(walls) {
+			vec := walls[issue.Wall]
+			vector.StrokeLine(screen, vec.X1+g.camera.X, vec.Y1+g.camera.Y, vec.X2+g.camera.X, vec.Y2+g.camera.Y, lineWidth, colornames.Red, true)
+		}
+		vector.StrokeCircle(screen, issue.Point.X+g.camera.X, issue.Point.Y+g.camera.Y, lineWidth*4, 1, colornames.Red, true)
 	}
 }
 
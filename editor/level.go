@@ -1,53 +1,53 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"os"
-	"strconv"
-	"strings"
+	"io/fs"
+	"level"
 )
 
 func (g *Game) writeLevel() {
-	buf := ""
-
-	buf = buf + fmt.Sprintf("%v,%v\n", pStartPos.X, pStartPos.Y)
-	for _, item := range walls {
-		buf = buf + fmt.Sprintf("%v,%v,%v,%v\n", item.X1/scaleDiv, item.Y1/scaleDiv, item.X2/scaleDiv, item.Y2/scaleDiv)
+	if readOnly {
+		fmt.Printf("Not saving, %v didn't read and would be overwritten\n", levelPath)
+		checkWalls()
+		return
 	}
+	lvl := level.Level{Meta: levelMeta, Start: pStartPos, Walls: walls, Sectors: sectors, Sprites: sprites, Doors: doors}
 
-	os.WriteFile(levelPath, []byte(buf), 0755)
+	if err := level.Write(levelPath, lvl); err != nil {
+		fmt.Printf("Unable to write %v: %v\n", levelPath, err)
+	}
+	checkWalls()
 }
 
 func readLevel() {
-	data, err := os.ReadFile(levelPath)
+	walls = []line32{}
+	sectors = []sector{}
+	sprites = []sprite{}
+	doors = []door{}
+
+	lvl, err := level.Read(levelPath)
 	if err != nil {
-		fmt.Printf("Unable to read %v\n", levelPath)
+		fmt.Printf("Unable to read level: %v\n", err)
+		// A missing file is a new level, but anything else would be lost on the first save
+		readOnly = !errors.Is(err, fs.ErrNotExist)
+		return
 	}
 
-	walls = []line32{}
-	text := string(data)
-	lines := strings.Split(text, "\n")
-
-	for l, line := range lines {
-		if l == 0 {
-			args := strings.Split(line, ",")
-			if len(args) != 2 {
-				continue
-			}
-			x1, _ := strconv.ParseFloat(args[0], 64)
-			y1, _ := strconv.ParseFloat(args[1], 64)
-			pStartPos = pos32{X: float32(x1), Y: float32(y1)}
-			continue
-		}
-		args := strings.Split(line, ",")
-		if len(args) != 4 {
-			continue
-		}
-		x1, _ := strconv.ParseFloat(args[0], 64)
-		y1, _ := strconv.ParseFloat(args[1], 64)
-		x2, _ := strconv.ParseFloat(args[2], 64)
-		y2, _ := strconv.ParseFloat(args[3], 64)
+	levelMeta = lvl.Meta
+	pStartPos = lvl.Start
+	walls = lvl.Walls
+	sectors = lvl.Sectors
+	sprites = lvl.Sprites
+	doors = lvl.Doors
+	checkWalls()
+}
 
-		walls = append(walls, line32{X1: float32(x1) / scaleDiv, Y1: float32(y1) / scaleDiv, X2: float32(x2) / scaleDiv, Y2: float32(y2) / scaleDiv})
+// Find the walls that don't close a loop, to point them out
+func checkWalls() {
+	_, issues = level.Level{Walls: walls, Doors: doors}.DetectSectors()
+	for _, issue := range issues {
+		fmt.Println(issue)
 	}
 }
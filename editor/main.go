@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"image/color"
 	"image/png"
+	"level"
 	"os"
 
 	"github.com/hajimehoshi/ebiten/v2"
@@ -13,9 +14,12 @@ const (
 	levelPath = "../level1.txt"
 
 	lineSnapDist = 10
+
+	heightStep   = 0.1 // How much a sector's floor or ceiling moves per key press
 	gridSnapDist = 5
 
-	scaleDiv   = 1
+	spriteTexture = "barrel" // Texture of newly placed sprites
+
 	lineWidth  = 2
 	gridBright = 25
 	gridSize   = 25
@@ -23,6 +27,12 @@ const (
 
 var (
 	walls     = []line32{}
+	sectors   = []sector{}
+	sprites   = []sprite{}
+	doors     = []door{}
+	issues    []level.Issue // Walls that don't close a loop, refreshed whenever the level is read or written
+	levelMeta map[string]string
+	readOnly  bool // The level file exists but didn't parse, so edits aren't saved over it
 	pStartPos pos32
 	gridColor = color.NRGBA{R: gridBright, G: gridBright, B: gridBright, A: 255}
 	bgImage   *ebiten.Image
@@ -25,8 +25,29 @@ func (g *Game) Update() error {
 		g.start = pos32{X: 0, Y: 0}
 	} else if inpututil.IsKeyJustPressed(ebiten.KeyP) {
 		handlePMode(g)
+	} else if inpututil.IsKeyJustPressed(ebiten.KeyS) && !g.createMode {
+		g.sectorMode = !g.sectorMode
+		g.sectorPoints = nil
+	} else if inpututil.IsKeyJustPressed(ebiten.KeyEnter) && g.sectorMode {
+		g.finishSector()
+	} else if inpututil.IsKeyJustPressed(ebiten.KeyF) && !g.createMode {
+		g.fillSector(wpos)
+	} else if inpututil.IsKeyJustPressed(ebiten.KeyO) && !g.createMode {
+		sprites = append(sprites, sprite{Pos: wpos, Texture: spriteTexture})
+		fmt.Printf("placed %v sprite at %v,%v\n", spriteTexture, wpos.X, wpos.Y)
+		g.writeLevel()
+	} else if inpututil.IsKeyJustPressed(ebiten.KeyT) {
+		g.toggleTwoSided(wpos)
+	} else if inpututil.IsKeyJustPressed(ebiten.KeyM) {
+		g.toggleMasked(wpos)
+	} else if inpututil.IsKeyJustPressed(ebiten.KeyG) {
+		g.toggleDoor(wpos)
+	} else if key, ok := heightKey(); ok {
+		g.adjustSectorHeight(wpos, key)
 	} else if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
-		if g.createMode {
+		if g.sectorMode {
+			g.sectorPoints = append(g.sectorPoints, snapPos(wpos, walls, lineSnapDist))
+		} else if g.createMode {
 			snappedPos := snapPos(wpos, walls, lineSnapDist)
 			if !g.secondClick && !g.firstClick {
 
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"level"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Close the sector being drawn and save it
+func (g *Game) finishSector() {
+	if len(g.sectorPoints) < 3 {
+		fmt.Println("A sector needs at least 3 corners")
+		return
+	}
+
+	sectors = append(sectors, sector{Points: g.sectorPoints, Floor: level.DefaultFloor, Ceiling: level.DefaultCeiling})
+	fmt.Printf("created sector with %v corners\n", len(g.sectorPoints))
+	g.writeLevel()
+
+	g.sectorPoints = nil
+	g.sectorMode = false
+}
+
+// Make a sector of the area enclosed by walls under the cursor
+func (g *Game) fillSector(wpos pos32) {
+	for _, found := range level.Detect(level.Level{Walls: walls, Doors: doors}.Boundaries()).Sectors {
+		if !found.Contains(wpos) {
+			continue
+		}
+		if sec := sectorAt(wpos); sec != nil && level.SamePolygon(sec.Points, found.Points) {
+			fmt.Println("That area is already a sector")
+			return
+		}
+
+		sectors = append(sectors, found)
+		fmt.Printf("filled sector with %v corners and %v holes\n", len(found.Points), len(found.Holes))
+		g.writeLevel()
+		return
+	}
+	fmt.Println("No closed loop of walls around the cursor")
+}
+
+// The sector under a point, the last listed if they overlap, as the game picks it
+func sectorAt(p pos32) *sector {
+	for i := len(sectors) - 1; i >= 0; i-- {
+		if sectors[i].Contains(p) {
+			return &sectors[i]
+		}
+	}
+	return nil
+}
+
+// Which height key was just pressed, if any
+func heightKey() (ebiten.Key, bool) {
+	for _, key := range []ebiten.Key{ebiten.KeyPageUp, ebiten.KeyPageDown, ebiten.KeyHome, ebiten.KeyEnd} {
+		if inpututil.IsKeyJustPressed(key) {
+			return key, true
+		}
+	}
+	return 0, false
+}
+
+// Raise or lower the floor (page up/down) or ceiling (home/end) of the sector under the cursor
+func (g *Game) adjustSectorHeight(wpos pos32, key ebiten.Key) {
+	sec := sectorAt(wpos)
+	if sec == nil {
+		return
+	}
+
+	switch key {
+	case ebiten.KeyPageUp:
+		sec.Floor = min(sec.Floor+heightStep, sec.Ceiling)
+	case ebiten.KeyPageDown:
+		sec.Floor -= heightStep
+	case ebiten.KeyHome:
+		sec.Ceiling += heightStep
+	case ebiten.KeyEnd:
+		sec.Ceiling = max(sec.Ceiling-heightStep, sec.Floor)
+	}
+	fmt.Printf("sector floor %.2f ceiling %.2f\n", sec.Floor, sec.Ceiling)
+	g.writeLevel()
+}
+
+// Make the wall under the cursor two-sided, or one-sided again
+func (g *Game) toggleTwoSided(wpos pos32) {
+	i := nearestWall(wpos, lineSnapDist)
+	if i < 0 {
+		return
+	}
+
+	walls[i].Attrs.TwoSided = !walls[i].Attrs.TwoSided
+	fmt.Printf("wall %v two-sided: %v\n", i, walls[i].Attrs.TwoSided)
+	g.writeLevel()
+}
+
+// Step the wall under the cursor through a sliding door, a swinging door, and a wall again
+func (g *Game) toggleDoor(wpos pos32) {
+	if i := nearestWall(wpos, lineSnapDist); i >= 0 {
+		doors = append(doors, door{Wall: walls[i]})
+		walls = append(walls[:i], walls[i+1:]...)
+		fmt.Printf("wall %v is now a sliding door\n", i)
+		g.writeLevel()
+		return
+	}
+
+	i := nearestDoor(wpos, lineSnapDist)
+	if i < 0 {
+		return
+	}
+	if doors[i].Motion == level.Slide {
+		doors[i].Motion = level.Swing
+		fmt.Printf("door %v swings\n", i)
+	} else {
+		walls = append(walls, doors[i].Wall)
+		doors = append(doors[:i], doors[i+1:]...)
+		fmt.Printf("door %v is a wall again\n", i)
+	}
+	g.writeLevel()
+}
+
+// Step the wall under the cursor through masked, masked and passable, and solid again
+func (g *Game) toggleMasked(wpos pos32) {
+	i := nearestWall(wpos, lineSnapDist)
+	if i < 0 {
+		return
+	}
+
+	attrs := &walls[i].Attrs
+	switch {
+	case !attrs.Masked:
+		attrs.Masked, attrs.Passable = true, false
+	case !attrs.Passable:
+		attrs.Passable = true
+	default:
+		attrs.Masked, attrs.Passable = false, false
+	}
+	fmt.Printf("wall %v masked: %v passable: %v\n", i, attrs.Masked, attrs.Passable)
+	g.writeLevel()
+}
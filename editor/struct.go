@@ -1,13 +1,17 @@
 package main
 
+import "level"
+
 // Define a struct for a 2D vector with start and end points
-type line32 struct {
-	X1, Y1, X2, Y2 float32
-}
+type line32 = level.Line32
 
-type pos32 struct {
-	X, Y float32
-}
+type pos32 = level.Pos32
+
+type sector = level.Sector
+
+type sprite = level.Sprite
+
+type door = level.Door
 
 // Game struct to hold game state
 type Game struct {
@@ -19,6 +23,9 @@ type Game struct {
 	firstClick,
 	secondClick bool
 
+	sectorMode   bool
+	sectorPoints []pos32 // Corners placed so far while drawing a sector
+
 	screenWidth,
 	screenHeight int
 }
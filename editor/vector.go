@@ -32,6 +32,43 @@ func snapPos(newPos pos32, existingPositions []line32, threshold float32) pos32
 	return newPos
 }
 
+// Distance from p to the closest point on a wall
+func distanceToSegment(p pos32, wall line32) float32 {
+	dx, dy := wall.X2-wall.X1, wall.Y2-wall.Y1
+	lengthSq := dx*dx + dy*dy
+	if lengthSq == 0 {
+		return distance(p, pos32{X: wall.X1, Y: wall.Y1})
+	}
+
+	t := ((p.X-wall.X1)*dx + (p.Y-wall.Y1)*dy) / lengthSq
+	t = math32.Max(0, math32.Min(1, t))
+	return distance(p, pos32{X: wall.X1 + t*dx, Y: wall.Y1 + t*dy})
+}
+
+// Index of the wall closest to p within threshold, or -1
+func nearestWall(p pos32, threshold float32) int {
+	nearest := -1
+	for i, wall := range walls {
+		if dist := distanceToSegment(p, wall); dist < threshold {
+			threshold = dist
+			nearest = i
+		}
+	}
+	return nearest
+}
+
+// Index of the door closest to p within threshold, -1 if there is none
+func nearestDoor(p pos32, threshold float32) int {
+	nearest := -1
+	for i, d := range doors {
+		if dist := distanceToSegment(p, d.Wall); dist < threshold {
+			threshold = dist
+			nearest = i
+		}
+	}
+	return nearest
+}
+
 func snapToGrid(pos pos32, gridSize, threshold float32) pos32 {
 	snapX := math32.Round(pos.X/gridSize) * gridSize
 	snapY := math32.Round(pos.Y/gridSize) * gridSize
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"image/color"
+	"os"
+	"strconv"
+	"strings"
+	"test/raycast"
+
+	"github.com/chewxy/math32"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"golang.org/x/image/colornames"
+)
+
+const (
+	automapZoom     = 20 // Pixels per world unit to start with
+	automapMinZoom  = 2
+	automapMaxZoom  = 200
+	automapZoomKey  = 1.03 // Zoom per tick while + or - is held
+	automapZoomRoll = 1.2  // Zoom per notch of the mouse wheel
+	automapPanSpeed = 8    // Pixels per tick while an arrow key is held
+
+	trailSpacing = 0.5  // Distance the player moves before the trail gets another point
+	maxTrail     = 4096 // Points kept, the oldest are dropped first
+)
+
+var savePath = flag.String("save", "save.txt", "file the automap is saved to on exit and restored from")
+
+// The ends of a level wall, to remember it by when the level is reloaded
+type segment [4]float32
+
+func segmentOf(wall line32) segment {
+	return segment{wall.X1, wall.Y1, wall.X2, wall.Y2}
+}
+
+// The full-screen map, which only shows what the player has seen
+type automapData struct {
+	show   bool
+	rotate bool    // Turn the map so the player always faces up
+	zoom   float32 // Pixels per world unit
+	pan    pos32   // Offset of the middle of the screen from the player
+
+	seen  map[segment]line32        // Level walls the player has seen some of, with their attributes
+	doors map[segment]bool          // Doors seen, by their closed position
+	nodes map[*raycast.BSPNode]bool // Nodes already revealed, so each column's hits are cheap to check
+	trail []pos32
+}
+
+var automap = newAutomap()
+
+func newAutomap() *automapData {
+	return &automapData{
+		zoom:  automapZoom,
+		seen:  map[segment]line32{},
+		doors: map[segment]bool{},
+		nodes: map[*raycast.BSPNode]bool{},
+	}
+}
+
+// Mark the walls and doors the columns hit as seen
+func (a *automapData) reveal(columns []raycast.RenderData, doorList []*raycast.Door) {
+	for i := range columns {
+		for _, node := range columns[i].Hits {
+			if a.nodes[node] {
+				continue
+			}
+			a.nodes[node] = true
+
+			isDoor := false
+			for _, d := range doorList {
+				if node == &d.Node {
+					a.doors[segmentOf(d.Def.Wall)] = true
+					isDoor = true
+				}
+			}
+			if !isDoor {
+				a.seen[segmentOf(node.Source)] = node.Source
+			}
+		}
+	}
+}
+
+// Match what's been seen to a level's walls and doors, after a reload or restoring a save.
+// Walls that are no longer in the level are forgotten, the rest are kept however the tree is split.
+func (a *automapData) relink(wallList []line32, doorList []*raycast.Door) {
+	seen := map[segment]line32{}
+	for _, wall := range wallList {
+		if _, ok := a.seen[segmentOf(wall)]; ok {
+			seen[segmentOf(wall)] = wall
+		}
+	}
+
+	doorsSeen := map[segment]bool{}
+	for _, d := range doorList {
+		if key := segmentOf(d.Def.Wall); a.doors[key] {
+			doorsSeen[key] = true
+		}
+	}
+
+	a.seen, a.doors = seen, doorsSeen
+	clear(a.nodes)
+}
+
+// Add the player's position to the trail once they've moved far enough from the last point
+func (a *automapData) record(pos pos32) {
+	if n := len(a.trail); n > 0 {
+		away := raycast.SubXY(pos, a.trail[n-1])
+		if raycast.DotXY(away, away) < trailSpacing*trailSpacing {
+			return
+		}
+	}
+	if len(a.trail) == maxTrail {
+		a.trail = append(a.trail[:0], a.trail[1:]...)
+	}
+	a.trail = append(a.trail, pos)
+}
+
+// Toggle the map with tab, and pan, zoom and rotate it while it's shown
+func (a *automapData) update() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyTab) {
+		a.show = !a.show
+		a.pan = pos32{}
+	}
+	if !a.show {
+		return
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyR) {
+		a.rotate = !a.rotate
+		a.pan = pos32{}
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyC) {
+		a.pan = pos32{}
+	}
+
+	if ebiten.IsKeyPressed(ebiten.KeyEqual) || ebiten.IsKeyPressed(ebiten.KeyNumpadAdd) {
+		a.zoom *= automapZoomKey
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyMinus) || ebiten.IsKeyPressed(ebiten.KeyNumpadSubtract) {
+		a.zoom /= automapZoomKey
+	}
+	if _, wheel := ebiten.Wheel(); wheel != 0 {
+		a.zoom *= math32.Pow(automapZoomRoll, float32(wheel))
+	}
+	a.zoom = min(max(a.zoom, automapMinZoom), automapMaxZoom)
+
+	// Arrows pan the way they point on screen, whichever way the map is turned
+	var move pos32
+	if ebiten.IsKeyPressed(ebiten.KeyArrowLeft) {
+		move.X--
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyArrowRight) {
+		move.X++
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyArrowUp) {
+		move.Y--
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyArrowDown) {
+		move.Y++
+	}
+	if move != (pos32{}) {
+		view := a.view(player.pos, player.angle, 0, 0)
+		a.pan = raycast.AddXY(a.pan, raycast.ScaleXY(view.unrotate(move), automapPanSpeed/a.zoom))
+	}
+}
+
+// How the world is laid out on screen for a frame of the automap or minimap
+type mapView struct {
+	centre   pos32   // World position in the middle of the screen
+	sin, cos float32 // Rotation from the world onto the screen
+	zoom     float32
+	midX     float32
+	midY     float32
+}
+
+// A map view centred on a point, turned so a player facing angle faces up the screen if rotate is set
+func newMapView(centre pos32, angle float32, rotate bool, zoom, midX, midY float32) mapView {
+	view := mapView{centre: centre, cos: 1, zoom: zoom, midX: midX, midY: midY}
+	if rotate {
+		// Up the screen is -Y
+		view.sin, view.cos = math32.Sincos(-math32.Pi/2 - angle)
+	}
+	return view
+}
+
+// The automap view around a player for a screen size
+func (a *automapData) view(pos pos32, angle float32, width, height int) mapView {
+	return newMapView(raycast.AddXY(pos, a.pan), angle, a.rotate, a.zoom, float32(width)/2, float32(height)/2)
+}
+
+// Screen position of a point in the world
+func (v mapView) project(p pos32) (x, y float32) {
+	rel := raycast.SubXY(p, v.centre)
+	x = rel.X*v.cos - rel.Y*v.sin
+	y = rel.X*v.sin + rel.Y*v.cos
+	return v.midX + x*v.zoom, v.midY + y*v.zoom
+}
+
+// Turn a screen direction back into a world one
+func (v mapView) unrotate(dir pos32) pos32 {
+	return pos32{X: dir.X*v.cos + dir.Y*v.sin, Y: -dir.X*v.sin + dir.Y*v.cos}
+}
+
+func (v mapView) line(screen *ebiten.Image, wall line32, width float32, clr color.Color) {
+	x1, y1 := v.project(pos32{X: wall.X1, Y: wall.Y1})
+	x2, y2 := v.project(pos32{X: wall.X2, Y: wall.Y2})
+	vector.StrokeLine(screen, x1, y1, x2, y2, width, clr, true)
+}
+
+// Draw the map over the whole screen, with the seen walls, the trail and the player
+func renderAutomap(screen *ebiten.Image) {
+	bounds := screen.Bounds()
+	vector.DrawFilledRect(screen, 0, 0, float32(bounds.Dx()), float32(bounds.Dy()), color.NRGBA{A: 220}, false)
+	view := automap.view(player.pos, player.angle, bounds.Dx(), bounds.Dy())
+
+	for i := 1; i < len(automap.trail); i++ {
+		x1, y1 := view.project(automap.trail[i-1])
+		x2, y2 := view.project(automap.trail[i])
+		vector.StrokeLine(screen, x1, y1, x2, y2, 1, colornames.Darkgoldenrod, true)
+	}
+
+	if *bspOverlay {
+		renderBSPOverlay(screen, view)
+		drawPlayerArrow(screen, view)
+		return
+	}
+
+	// Seen through walls are coloured as in the editor
+	for _, wall := range automap.seen {
+		clr := color.Color(colornames.White)
+		if wall.Attrs.Masked {
+			clr = colornames.Plum
+		} else if wall.Attrs.TwoSided {
+			clr = colornames.Lightskyblue
+		}
+		view.line(screen, wall, 2, clr)
+	}
+	for _, d := range doors {
+		if automap.doors[segmentOf(d.Def.Wall)] && d.Solid() {
+			view.line(screen, d.Node.Wall, 2, colornames.Orange)
+		}
+	}
+
+	drawPlayerArrow(screen, view)
+}
+
+// Draw the player as an arrow pointing the way they face
+func drawPlayerArrow(screen *ebiten.Image, view mapView) {
+	tip := raycast.AngleToXY(player.angle, 0.6)
+	side := raycast.AngleToXY(player.angle+math32.Pi/2, 0.3)
+	back := raycast.ScaleXY(tip, -0.5)
+	x0, y0 := view.project(raycast.AddXY(player.pos, tip))
+	x1, y1 := view.project(raycast.AddXY(player.pos, raycast.AddXY(back, side)))
+	x2, y2 := view.project(raycast.AddXY(player.pos, raycast.SubXY(back, side)))
+	vector.StrokeLine(screen, x0, y0, x1, y1, 2, colornames.Yellow, true)
+	vector.StrokeLine(screen, x1, y1, x2, y2, 2, colornames.Yellow, true)
+	vector.StrokeLine(screen, x2, y2, x0, y0, 2, colornames.Yellow, true)
+}
+
+// Write what's been seen of a level to a file, to restore next time
+func (a *automapData) save(path, levelPath string) error {
+	var buf strings.Builder
+	buf.WriteString("# Automap, the walls and doors seen\n")
+	fmt.Fprintf(&buf, "level %v\n", levelPath)
+	for key := range a.seen {
+		fmt.Fprintf(&buf, "wall %v\n", formatSegment(key))
+	}
+	for key := range a.doors {
+		fmt.Fprintf(&buf, "door %v\n", formatSegment(key))
+	}
+	return os.WriteFile(path, []byte(buf.String()), 0644)
+}
+
+func formatSegment(s segment) string {
+	return fmt.Sprintf("%v,%v,%v,%v", s[0], s[1], s[2], s[3])
+}
+
+// Restore what's been seen of a level from a save, leaving the map empty if the save is for another level.
+// The walls have no attributes until relink matches them to the tree.
+func (a *automapData) load(path, levelPath string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	seen, doorsSeen := map[segment]line32{}, map[segment]bool{}
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 {
+			return fmt.Errorf("%v line %v: expected a keyword and a value", path, lineNum)
+		}
+
+		switch fields[0] {
+		case "level":
+			if fields[1] != levelPath {
+				return nil
+			}
+		case "wall", "door":
+			key, err := parseSegment(fields[1])
+			if err != nil {
+				return fmt.Errorf("%v line %v: %w", path, lineNum, err)
+			}
+			if fields[0] == "wall" {
+				seen[key] = line32{X1: key[0], Y1: key[1], X2: key[2], Y2: key[3]}
+			} else {
+				doorsSeen[key] = true
+			}
+		default:
+			return fmt.Errorf("%v line %v: unknown keyword %q", path, lineNum, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	a.seen, a.doors = seen, doorsSeen
+	return nil
+}
+
+func parseSegment(text string) (segment, error) {
+	var s segment
+	parts := strings.Split(text, ",")
+	if len(parts) != len(s) {
+		return s, fmt.Errorf("expected x1,y1,x2,y2, got %q", text)
+	}
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(part, 32)
+		if err != nil {
+			return s, err
+		}
+		s[i] = float32(value)
+	}
+	return s, nil
+}
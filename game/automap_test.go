@@ -0,0 +1,122 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"test/raycast"
+	"testing"
+
+	"github.com/chewxy/math32"
+)
+
+func TestAutomapReveal(t *testing.T) {
+	lvl, root, list := doorTestLevel(t)
+	columns := castTestColumns(lvl, root, list, pos32{X: 10, Y: 17})
+	automap := newAutomap()
+	automap.reveal(columns, list)
+
+	// Looking at the dividing wall, both doors are seen but not the wall behind the player
+	if len(automap.doors) != 2 {
+		t.Errorf("%v doors seen, want both", len(automap.doors))
+	}
+	behind := pos32{X: 10, Y: 20}
+	for _, wall := range automap.seen {
+		if closest := raycast.ClosestPointOnSegment(behind, wall); closest == behind {
+			t.Errorf("wall %v behind the player was seen", wall)
+		}
+	}
+	if len(automap.seen) == 0 {
+		t.Fatal("no walls seen")
+	}
+
+	// What's seen is the level's walls, not the pieces the tree split them into
+	for key, wall := range automap.seen {
+		if !slices.Contains(lvl.Walls, wall) {
+			t.Errorf("seen wall %v isn't one of the level's walls", key)
+		}
+	}
+
+	// Editing the level keeps what was seen of the walls left alone, however the new tree splits them
+	seen := len(automap.seen)
+	edited := append(slices.Clone(lvl.Walls), line32{X1: 0.5, Y1: 0.5, X2: 19.5, Y2: 19.5})
+	automap.relink(edited, raycast.NewDoors(lvl.Doors, lvl.Sectors))
+	if len(automap.seen) != seen || len(automap.doors) != 2 || len(automap.nodes) != 0 {
+		t.Errorf("%v walls and %v doors seen after relinking, want %v and 2", len(automap.seen), len(automap.doors), seen)
+	}
+
+	// Walls that have gone from the level are forgotten
+	automap.relink(nil, nil)
+	if len(automap.seen) != 0 || len(automap.doors) != 0 {
+		t.Errorf("%v walls and %v doors seen in an empty level", len(automap.seen), len(automap.doors))
+	}
+}
+
+func TestAutomapSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "save.txt")
+	saved := newAutomap()
+	saved.seen[segment{1.5, 2, 1.0 / 3, 4}] = line32{X1: 1.5, Y1: 2, X2: 1.0 / 3, Y2: 4}
+	saved.doors[segment{5, 0, 6, 0}] = true
+	if err := saved.save(path, "level.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := newAutomap()
+	if err := loaded.load(path, "level.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.seen) != 1 || len(loaded.doors) != 1 {
+		t.Fatalf("loaded %v walls and %v doors, want 1 and 1", len(loaded.seen), len(loaded.doors))
+	}
+	if _, ok := loaded.seen[segment{1.5, 2, 1.0 / 3, 4}]; !ok {
+		t.Errorf("loaded walls %v, want the saved one exactly", loaded.seen)
+	}
+
+	// A save for another level isn't restored
+	other := newAutomap()
+	if err := other.load(path, "other.txt"); err != nil || len(other.seen) != 0 {
+		t.Errorf("loading another level's save gave %v walls, error %v", len(other.seen), err)
+	}
+}
+
+func TestAutomapView(t *testing.T) {
+	automap := newAutomap()
+	pos := pos32{X: 3, Y: 4}
+	ahead := raycast.AddXY(pos, raycast.AngleToXY(0.7, 1))
+
+	// North up, the map is the world scaled about the player
+	view := automap.view(pos, 0.7, 200, 100)
+	if x, y := view.project(pos); x != 100 || y != 50 {
+		t.Errorf("player drawn at %v,%v, want the middle of the screen", x, y)
+	}
+
+	// Rotating, what's ahead of the player is straight up the screen
+	automap.rotate = true
+	view = automap.view(pos, 0.7, 200, 100)
+	x, y := view.project(ahead)
+	if math32.Abs(x-100) > 0.001 || math32.Abs(y-(50-automapZoom)) > 0.001 {
+		t.Errorf("point ahead drawn at %v,%v, want straight up from the player", x, y)
+	}
+
+	// Panning right on screen moves the other way from the world ahead
+	right := view.unrotate(pos32{X: 1})
+	if x, y := view.project(raycast.AddXY(pos, right)); math32.Abs(x-(100+automapZoom)) > 0.001 || math32.Abs(y-50) > 0.001 {
+		t.Errorf("point to the right drawn at %v,%v, want right of the player", x, y)
+	}
+}
+
+func TestAutomapTrail(t *testing.T) {
+	automap := newAutomap()
+	for i := 0; i < 100; i++ {
+		automap.record(pos32{X: float32(i) * trailSpacing / 4})
+	}
+	if len(automap.trail) != 25 {
+		t.Errorf("%v trail points, want one every %v", len(automap.trail), trailSpacing)
+	}
+
+	for i := 0; i < maxTrail+10; i++ {
+		automap.record(pos32{Y: float32(i)})
+	}
+	if len(automap.trail) != maxTrail || automap.trail[len(automap.trail)-1].Y != maxTrail+9 {
+		t.Errorf("%v trail points ending at %v, want the last %v", len(automap.trail), automap.trail[len(automap.trail)-1], maxTrail)
+	}
+}
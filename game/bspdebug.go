@@ -0,0 +1,193 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"test/raycast"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"golang.org/x/image/colornames"
+)
+
+var bspOverlay = flag.Bool("bspdebug", false, "draw the BSP tree on the automap instead of the walls seen, b toggles it")
+
+const (
+	traceStepTicks  = 10       // Ticks each step of the traced ray's walk is shown for before the next
+	tracePauseSteps = 3        // Steps to hold the finished walk for before starting again
+	traceMove       = 1.0 / 32 // How far j and l move the traced ray across the view
+	traceReach      = 50       // How far the traced ray is drawn when it doesn't hit anything
+	partitionAlpha  = 96       // Partition lines are fainter than the walls on them
+)
+
+var (
+	traceAt    float32 = 0.5 // Where the traced ray is across the view, 0 at the left to 1 at the right
+	traceTick  int
+	traceData  raycast.RenderData // Reused for the traced column
+	traceSteps []raycast.TraceStep
+)
+
+var (
+	cellColor   = color.NRGBA{G: 96, B: 96, A: 96}
+	traceColors = [...]color.Color{raycast.TraceMissed: colornames.Yellow, raycast.TraceHit: colornames.Red, raycast.TraceCulled: colornames.Gray}
+)
+
+// Toggle the overlay with b and move the traced ray with j and l, while the automap is shown
+func updateBSPOverlay() {
+	if !automap.show {
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyB) {
+		*bspOverlay = !*bspOverlay
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyJ) {
+		traceAt, traceTick = max(traceAt-traceMove, 0), 0
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyL) {
+		traceAt, traceTick = min(traceAt+traceMove, 1), 0
+	}
+	traceTick++
+}
+
+// A box around every wall, with a margin, for the root of the tree to divide up
+func levelBounds(lines []line32, margin float32) []pos32 {
+	if len(lines) == 0 {
+		return nil
+	}
+	minX, minY := min(lines[0].X1, lines[0].X2), min(lines[0].Y1, lines[0].Y2)
+	maxX, maxY := max(lines[0].X1, lines[0].X2), max(lines[0].Y1, lines[0].Y2)
+	for _, wall := range lines[1:] {
+		minX, maxX = min(minX, wall.X1, wall.X2), max(maxX, wall.X1, wall.X2)
+		minY, maxY = min(minY, wall.Y1, wall.Y2), max(maxY, wall.Y1, wall.Y2)
+	}
+	minX, minY, maxX, maxY = minX-margin, minY-margin, maxX+margin, maxY+margin
+	return []pos32{{X: minX, Y: minY}, {X: maxX, Y: minY}, {X: maxX, Y: maxY}, {X: minX, Y: maxY}}
+}
+
+// The part of a convex polygon in front of a partition line, or behind it if front is false
+func clipPolygon(polygon []pos32, partition line32, front bool) []pos32 {
+	sign := float32(1)
+	if !front {
+		sign = -1
+	}
+
+	var clipped []pos32
+	for i, a := range polygon {
+		b := polygon[(i+1)%len(polygon)]
+		sideA, sideB := sign*raycast.SideDistance(a, partition), sign*raycast.SideDistance(b, partition)
+		if sideA >= 0 {
+			clipped = append(clipped, a)
+		}
+		if (sideA >= 0) != (sideB >= 0) {
+			t := sideA / (sideA - sideB)
+			clipped = append(clipped, pos32{X: a.X + t*(b.X-a.X), Y: a.Y + t*(b.Y-a.Y)})
+		}
+	}
+	return clipped
+}
+
+// Where a partition line runs across a convex polygon
+func lineAcrossPolygon(polygon []pos32, partition line32) (a, b pos32, ok bool) {
+	var ends []pos32
+	for i, p := range polygon {
+		q := polygon[(i+1)%len(polygon)]
+		sideP, sideQ := raycast.SideDistance(p, partition), raycast.SideDistance(q, partition)
+		if (sideP >= 0) != (sideQ >= 0) {
+			t := sideP / (sideP - sideQ)
+			ends = append(ends, pos32{X: p.X + t*(q.X-p.X), Y: p.Y + t*(q.Y-p.Y)})
+		}
+	}
+	if len(ends) < 2 {
+		return a, b, false
+	}
+	return ends[0], ends[1], true
+}
+
+// The last node on the way down the tree to a point, how deep it is, and the empty region the point is in
+func playerCell(root *raycast.BSPNode, pos pos32, bounds []pos32) (*raycast.BSPNode, int, []pos32) {
+	var last *raycast.BSPNode
+	depth := 0
+	region := bounds
+	for node := root; node != nil; depth++ {
+		last = node
+		if raycast.SideDistance(pos, node.Wall) >= 0 {
+			region = clipPolygon(region, node.Wall, true)
+			node = node.Front
+		} else {
+			region = clipPolygon(region, node.Wall, false)
+			node = node.Back
+		}
+	}
+	return last, depth, region
+}
+
+// Colour for a depth in the tree, from red at the root through to blue at the deepest
+func depthColor(depth, deepest int) color.NRGBA {
+	hue := float32(0)
+	if deepest > 1 {
+		hue = 240 * float32(depth-1) / float32(deepest-1)
+	}
+	return raycast.HSVtoRGB(hue, 0.8, 1)
+}
+
+// Draw every wall coloured by its depth, and each partition line across the region its node divides
+func drawBSPNode(screen *ebiten.Image, view mapView, node *raycast.BSPNode, depth, deepest int, region []pos32) {
+	if node == nil || len(region) < 3 {
+		return
+	}
+
+	clr := depthColor(depth, deepest)
+	if a, b, ok := lineAcrossPolygon(region, node.Wall); ok {
+		faded := color.NRGBA{R: clr.R, G: clr.G, B: clr.B, A: partitionAlpha}
+		view.line(screen, line32{X1: a.X, Y1: a.Y, X2: b.X, Y2: b.Y}, 1, faded)
+	}
+	view.line(screen, node.Wall, 2, clr)
+
+	drawBSPNode(screen, view, node.Front, depth+1, deepest, clipPolygon(region, node.Wall, true))
+	drawBSPNode(screen, view, node.Back, depth+1, deepest, clipPolygon(region, node.Wall, false))
+}
+
+// Draw the tree over the automap, with the player's region, the traced ray's walk so far and the stats
+func renderBSPOverlay(screen *ebiten.Image, view mapView) {
+	if bspData == nil {
+		return
+	}
+	stats := raycast.TreeStats(bspData, walls)
+	bounds := levelBounds(walls, 1)
+
+	cell, cellDepth, region := playerCell(bspData, player.pos, bounds)
+	if len(region) >= 3 {
+		fillFan(screen, view, region[0], region[1:], cellColor)
+	}
+	drawBSPNode(screen, view, bspData, 1, stats.Depth, bounds)
+	view.line(screen, cell.Wall, 4, colornames.White)
+
+	// The traced ray's walk, a step at a time
+	col := min(int(traceAt*float32(sceneFrame.Width)), sceneFrame.Width-1)
+	traceSteps = traceSteps[:0]
+	sceneFrame.WalkColumn(col, &traceData, &traceSteps)
+
+	reach := float32(traceReach)
+	if depth, blocked := traceData.BlockedAt(); blocked {
+		reach = depth
+	}
+	end := raycast.AddXY(player.pos, raycast.ScaleXY(traceData.RayDir, reach))
+	view.line(screen, line32{X1: player.pos.X, Y1: player.pos.Y, X2: end.X, Y2: end.Y}, 1, colornames.Lime)
+
+	shown := min(traceTick/traceStepTicks%(len(traceSteps)+tracePauseSteps)+1, len(traceSteps))
+	counts := [len(traceColors)]int{}
+	for i, step := range traceSteps[:shown] {
+		counts[step.Kind]++
+		view.line(screen, step.Node.Wall, 3, traceColors[step.Kind])
+		x, y := view.project(pos32{X: (step.Node.Wall.X1 + step.Node.Wall.X2) / 2, Y: (step.Node.Wall.Y1 + step.Node.Wall.Y2) / 2})
+		ebitenutil.DebugPrintAt(screen, fmt.Sprint(i+1), int(x)+2, int(y)+2)
+	}
+
+	text := fmt.Sprintf("BSP %v\nPlayer's node is %v deep, in a region with %v corners\n"+
+		"Ray at column %v, step %v of %v: %v missed, %v hit, %v culled\n"+
+		"b = BSP overlay, j/l = move the traced ray",
+		stats, cellDepth, len(region), col, shown, len(traceSteps), counts[raycast.TraceMissed], counts[raycast.TraceHit], counts[raycast.TraceCulled])
+	ebitenutil.DebugPrintAt(screen, text, 0, screen.Bounds().Dy()-64)
+}
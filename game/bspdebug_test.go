@@ -0,0 +1,89 @@
+package main
+
+import (
+	"level"
+	"test/raycast"
+	"testing"
+
+	"github.com/chewxy/math32"
+)
+
+// Load the level the game starts with
+func loadTestLevel(t testing.TB) level.Level {
+	lvl, err := raycast.LoadLevel(levelPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lvl
+}
+
+func TestClipPolygon(t *testing.T) {
+	square := []pos32{{X: 0, Y: 0}, {X: 4, Y: 0}, {X: 4, Y: 4}, {X: 0, Y: 4}}
+	partition := line32{X1: 1, Y1: -1, X2: 1, Y2: 5}
+
+	front, back := clipPolygon(square, partition, true), clipPolygon(square, partition, false)
+	for name, region := range map[string][]pos32{"front": front, "back": back} {
+		if len(region) != 4 {
+			t.Errorf("%v region %v, want a rectangle", name, region)
+		}
+	}
+	if got := polygonArea(front) + polygonArea(back); math32.Abs(got-16) > 0.001 {
+		t.Errorf("halves cover %v, want the square's 16", got)
+	}
+
+	a, b, ok := lineAcrossPolygon(square, partition)
+	if !ok || a.X != 1 || b.X != 1 || math32.Abs(a.Y-b.Y) != 4 {
+		t.Errorf("partition crosses the square from %v to %v, want along x=1", a, b)
+	}
+}
+
+// Area of a convex polygon, either way round
+func polygonArea(polygon []pos32) float32 {
+	var area float32
+	for i, a := range polygon {
+		b := polygon[(i+1)%len(polygon)]
+		area += a.X*b.Y - b.X*a.Y
+	}
+	return math32.Abs(area) / 2
+}
+
+func TestPlayerCell(t *testing.T) {
+	lvl := loadTestLevel(t)
+	root := raycast.BuildBSPTree(lvl.Walls)
+	bounds := levelBounds(lvl.Walls, 1)
+
+	node, depth, region := playerCell(root, lvl.Start, bounds)
+	if node == nil || depth < 1 || len(region) < 3 {
+		t.Fatalf("player in node %v at depth %v with region %v", node, depth, region)
+	}
+
+	// The region is convex and the player is inside it
+	for i, a := range region {
+		b := region[(i+1)%len(region)]
+		if raycast.SideDistance(lvl.Start, line32{X1: a.X, Y1: a.Y, X2: b.X, Y2: b.Y})*raycast.SideDistance(region[(i+2)%len(region)], line32{X1: a.X, Y1: a.Y, X2: b.X, Y2: b.Y}) < 0 {
+			t.Errorf("player is outside edge %v of the region", i)
+		}
+	}
+
+	// No wall cuts through the middle of the region
+	var centre pos32
+	for _, p := range region {
+		centre = raycast.AddXY(centre, raycast.ScaleXY(p, 1/float32(len(region))))
+	}
+	for _, wall := range lvl.Walls {
+		if dist, _, ok := raycast.RayIntersectsSegment(lvl.Start, raycast.SubXY(centre, lvl.Start), wall); ok && dist <= 1 {
+			t.Errorf("wall %v runs through the player's region", wall)
+		}
+	}
+}
+
+func TestDepthColorOpaque(t *testing.T) {
+	for depth := 1; depth <= 5; depth++ {
+		if c := depthColor(depth, 5); c.A != 255 {
+			t.Errorf("depth %v colour %v, want opaque", depth, c)
+		}
+	}
+	if c := depthColor(1, 1); c.A != 255 {
+		t.Errorf("single level colour %v, want opaque", c)
+	}
+}
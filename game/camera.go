@@ -0,0 +1,22 @@
+package main
+
+import (
+	"test/raycast"
+
+	"github.com/chewxy/math32"
+)
+
+const (
+	minFOVDeg  = 30
+	maxFOVDeg  = 150
+	fovStepDeg = 1
+)
+
+// Change the FOV, clamped to a sane range
+func setFOV(fovDeg float32) {
+	FOVDeg = math32.Max(minFOVDeg, math32.Min(maxFOVDeg, fovDeg))
+
+	renderLock.Lock()
+	view = raycast.NewCamera(FOVDeg, frameWidth)
+	renderLock.Unlock()
+}
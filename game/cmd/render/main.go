@@ -0,0 +1,67 @@
+// Render renders one frame of a level on the CPU and writes it as a PNG, without opening a window.
+// Run it from the game directory so the default level and texture paths resolve, e.g.
+//
+//	go run ./cmd/render -x 27.4 -y 37.1 -out frame.png
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/png"
+	"log"
+	"math"
+	"os"
+	"test/raycast"
+)
+
+var (
+	outPath    = flag.String("out", "frame.png", "PNG file to write")
+	levelPath  = flag.String("level", "../level1.txt", "level file to render")
+	textureDir = flag.String("textures", "textures", "directory of PNG textures")
+	posX       = flag.Float64("x", math.NaN(), "camera X in world units, defaults to the level start")
+	posY       = flag.Float64("y", math.NaN(), "camera Y in world units, defaults to the level start")
+	angle      = flag.Float64("angle", -math.Pi/2, "camera angle in radians")
+	width      = flag.Int("width", 1280, "image width")
+	height     = flag.Int("height", 720, "image height")
+	fovDeg     = flag.Float64("fov", 90, "horizontal field of view in degrees")
+)
+
+func main() {
+	flag.Parse()
+	if err := renderPNG(*outPath); err != nil {
+		log.Fatalln(err.Error())
+	}
+}
+
+// Render a frame with the command line options and write it as a PNG
+func renderPNG(path string) error {
+	lvl, err := raycast.LoadLevel(*levelPath)
+	if err != nil {
+		return err
+	}
+
+	pos := lvl.Start
+	if !math.IsNaN(*posX) {
+		pos.X = float32(*posX)
+	}
+	if !math.IsNaN(*posY) {
+		pos.Y = float32(*posY)
+	}
+	if *width <= 0 || *height <= 0 {
+		return fmt.Errorf("bad image size %vx%v", *width, *height)
+	}
+
+	textures := raycast.LoadTextures(*textureDir)
+	textures.Check(lvl.Walls)
+	img := raycast.RenderFrame(lvl, textures, pos, float32(*angle), *width, *height, float32(*fovDeg))
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"level"
+	"test/raycast"
+)
+
+const (
+	useRange  = 1.0 // Farthest the player can reach a door to use it
+	nearRange = 1.5 // Closest the player can come to a proximity door before it opens
+	keyRange  = raycast.PlayerRadius + 0.25
+
+	messageTime = 2 // Seconds a message stays on screen
+)
+
+var (
+	doors   []*raycast.Door
+	keys    = map[string]bool{} // Keys the player has picked up
+	message string              // Shown under the frame timings, set by showMessage
+	shownAt float32             // Seconds left to show the message
+)
+
+// Start a door opening if the player has the key for it, and say why not if they don't
+func trigger(d *raycast.Door) {
+	if d.Def.Key != "" && !keys[d.Def.Key] {
+		showMessage(fmt.Sprintf("You need the %v key", d.Def.Key))
+		return
+	}
+	d.Start()
+}
+
+// The door in front of the player within reach, nil if there isn't one or a wall is in the way
+func usableDoor(root *raycast.BSPNode, list []*raycast.Door, pos pos32, angle float32) *raycast.Door {
+	var nearest *raycast.Door
+	var nearestDepth float32 = useRange
+	facing := raycast.AngleToXY(angle, 1)
+	for _, d := range list {
+		if depth, _, hit := raycast.RayIntersectsSegment(pos, facing, d.Def.Wall); hit && depth <= nearestDepth {
+			nearest, nearestDepth = d, depth
+		}
+	}
+	if nearest != nil && raycast.CastRay(root, nil, pos, facing, nearestDepth, (*raycast.BSPNode).SeeThrough).Found {
+		return nil
+	}
+	return nearest
+}
+
+// Open doors the player uses or comes near, and move every door on by dt seconds
+func updateDoors(root *raycast.BSPNode, list []*raycast.Door, pos pos32, angle float32, use bool, dt float32) {
+	if use {
+		if d := usableDoor(root, list, pos, angle); d != nil {
+			if d.Opening && !d.Def.Near {
+				d.Opening = false
+			} else {
+				trigger(d)
+			}
+		}
+	}
+
+	for _, d := range list {
+		if d.Def.Near {
+			away := raycast.SubXY(pos, raycast.ClosestPointOnSegment(pos, d.Def.Wall))
+			if raycast.DotXY(away, away) < nearRange*nearRange {
+				trigger(d)
+			}
+		}
+		d.Update(dt, pos, raycast.PlayerRadius)
+	}
+
+	shownAt = max(shownAt-dt, 0)
+}
+
+// Pick up the keys the player walks over, returning the sprites left behind
+func pickUpKeys(list []level.Sprite, pos pos32) []level.Sprite {
+	kept := list
+	for i := 0; i < len(kept); i++ {
+		spr := &kept[i]
+		away := raycast.SubXY(pos, spr.Pos)
+		if spr.Key == "" || raycast.DotXY(away, away) > keyRange*keyRange {
+			continue
+		}
+
+		keys[spr.Key] = true
+		showMessage(fmt.Sprintf("Picked up the %v key", spr.Key))
+
+		// Copy rather than delete in place, the level still holds the original
+		kept = append(kept[:i:i], kept[i+1:]...)
+		i--
+	}
+	return kept
+}
+
+// Put a message on screen for a couple of seconds
+func showMessage(text string) {
+	message = text
+	shownAt = messageTime
+}
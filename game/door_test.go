@@ -0,0 +1,135 @@
+package main
+
+import (
+	"level"
+	"test/raycast"
+	"testing"
+
+	"github.com/chewxy/math32"
+)
+
+// Size of the frames the tests cast
+const (
+	testFrameWidth  = 320
+	testFrameHeight = 180
+)
+
+// The rooms of the renderer's doors test level, with the level's doors closed
+func doorTestLevel(t *testing.T) (level.Level, *raycast.BSPNode, []*raycast.Door) {
+	lvl, err := raycast.LoadLevel("raycast/testdata/doors.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := raycast.BuildBSPTree(lvl.Walls)
+	raycast.AssignSectors(root, lvl.Sectors)
+	return lvl, root, raycast.NewDoors(lvl.Doors, lvl.Sectors)
+}
+
+// Cast every column of a frame looking up the level from pos, with a 90 degree FOV
+func castTestColumns(lvl level.Level, root *raycast.BSPNode, list []*raycast.Door, pos pos32) []raycast.RenderData {
+	frame := &raycast.Frame{
+		Pixels: make([]byte, testFrameWidth*testFrameHeight*4), Width: testFrameWidth, Height: testFrameHeight,
+		Pos: pos, Angle: -math32.Pi / 2, EyeZ: raycast.EyeZAt(lvl.Sectors, pos), Cam: raycast.NewCamera(90, testFrameWidth),
+		Root: root, Doors: list, Textures: raycast.LoadTextures(textureDir),
+	}
+	frame.Aim()
+
+	columns := make([]raycast.RenderData, testFrameWidth)
+	for col := range columns {
+		frame.CastColumn(col, &columns[col])
+	}
+	return columns
+}
+
+// Run the doors for a number of seconds at the game's tick rate
+func runDoors(root *raycast.BSPNode, list []*raycast.Door, pos pos32, seconds float32) {
+	const dt = 1.0 / 60
+	for i := 0; i < int(seconds/dt); i++ {
+		updateDoors(root, list, pos, 0, false, dt)
+	}
+}
+
+func TestDoorBlocksUntilOpen(t *testing.T) {
+	lvl, root, list := doorTestLevel(t)
+	slide := list[0]
+
+	// Walking straight at the sliding door stops at it
+	pos := lvl.Start
+	for i := 0; i < 50; i++ {
+		pos = raycast.MoveAndSlide(root, list, pos, pos32{X: 0, Y: -0.1}, raycast.PlayerRadius)
+	}
+	if pos.Y < 10+raycast.PlayerRadius-0.001 {
+		t.Fatalf("walked through the closed door to %v", pos)
+	}
+
+	// Used, it slides out of the way and the player can go through
+	updateDoors(root, list, pos, -math32.Pi/2, true, 0)
+	runDoors(root, list, pos, 1)
+	if slide.Open != 1 || slide.Solid() {
+		t.Fatalf("door is %v open, want fully open", slide.Open)
+	}
+	for i := 0; i < 50; i++ {
+		pos = raycast.MoveAndSlide(root, list, pos, pos32{X: 0, Y: -0.1}, raycast.PlayerRadius)
+	}
+	if pos.Y > 10-raycast.PlayerRadius {
+		t.Errorf("stopped at %v, want through the open doorway", pos)
+	}
+
+	// Once the player has gone, it closes again after waiting
+	runDoors(root, list, pos, level.DefaultDoorWait+1)
+	if slide.Open != 0 {
+		t.Errorf("door is %v open after the wait, want closed", slide.Open)
+	}
+}
+
+func TestDoorDoesntCloseOnPlayer(t *testing.T) {
+	_, root, list := doorTestLevel(t)
+	slide := list[0]
+	inDoorway := pos32{X: 6.5, Y: 10}
+
+	slide.Start()
+	runDoors(root, list, inDoorway, level.DefaultDoorWait+2)
+	if slide.Open == 0 {
+		t.Error("door closed on the player standing in the doorway")
+	}
+}
+
+func TestLockedDoor(t *testing.T) {
+	lvl, root, list := doorTestLevel(t)
+	locked := list[1]
+	defer clear(keys)
+	clear(keys)
+
+	inFront := pos32{X: 14.5, Y: 10.5}
+	updateDoors(root, list, inFront, -math32.Pi/2, true, 0)
+	runDoors(root, list, inFront, 1)
+	if locked.Open != 0 {
+		t.Fatalf("locked door opened %v without the key", locked.Open)
+	}
+	if shownAt == 0 {
+		t.Error("no message saying the key is needed")
+	}
+
+	// Walking over the key picks it up, and the door opens
+	left := pickUpKeys(lvl.Sprites, lvl.Sprites[0].Pos)
+	if len(left) != 0 || len(lvl.Sprites) != 1 {
+		t.Errorf("%v sprites left after picking up the key, and %v in the level, want 0 and 1", len(left), len(lvl.Sprites))
+	}
+	updateDoors(root, list, inFront, -math32.Pi/2, true, 0)
+	runDoors(root, list, inFront, 1.5)
+	if locked.Open != 1 {
+		t.Errorf("door with the key is %v open, want fully open", locked.Open)
+	}
+}
+
+func TestUsableDoorBehindWall(t *testing.T) {
+	root := raycast.BuildBSPTree([]line32{{X1: -5, Y1: 1, X2: 5, Y2: 1}})
+	list := raycast.NewDoors([]level.Door{{Wall: line32{X1: -1, Y1: 0.5, X2: 1, Y2: 0.5}}}, nil)
+
+	if d := usableDoor(nil, list, pos32{X: 0, Y: 1.4}, -math32.Pi/2); d != list[0] {
+		t.Errorf("door within reach is %v, want it usable", d)
+	}
+	if d := usableDoor(root, list, pos32{X: 0, Y: 1.4}, -math32.Pi/2); d != nil {
+		t.Error("can use a door behind a wall")
+	}
+}
@@ -2,22 +2,15 @@ package main
 
 import (
 	"fmt"
-	"image/color"
-	"math"
 	"sync"
+	"test/raycast"
 	"time"
 
-	"github.com/chewxy/math32"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 )
 
-const (
-	lightIntensity = 1000
-)
-
 var (
-	wallColor   color.NRGBA = HSVtoRGB(180, 0.0, 0.8)
 	frameNumber int
 )
 
@@ -34,9 +27,23 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	frameNumber++
 	start := time.Now()
 
-	//renderFloorAndCeiling(screen)
-	renderScene(screen)
-	renderMinimap(screen)
+	resizeFrame(screen.Bounds().Dx(), screen.Bounds().Dy())
+	aimScene()
+	renderFloorAndCeiling()
+	castScene()
+	automap.reveal(rayList, doors)
+	pool.run(frameWidth, workSize, shadeColumns)
+	frameImage.WritePixels(framePixels)
+	if !*cpuWalls {
+		renderWallSlice(frameImage)
+		renderLayers(frameImage)
+	}
+	drawFrame(screen)
+	if automap.show {
+		renderAutomap(screen)
+	} else {
+		renderMinimap(screen)
+	}
 
 	took := time.Since(start).Microseconds()
 	if frameNumber%6000 == 0 {
@@ -46,75 +53,67 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	worstFrame = max(worstFrame, int(took))
 	bestFrame = min(bestFrame, int(took))
 	ebitenutil.DebugPrint(screen, fmt.Sprintf("FPS: %3v, Took: %4vus / Max: %4vus / Min: %4vus", int(ebiten.ActualFPS()), took, worstFrame, bestFrame))
+	if shownAt > 0 {
+		ebitenutil.DebugPrintAt(screen, message, 0, 16)
+	}
 }
 
-var FOVDeg float32 = 60
-var fovRadians = FOVDeg * (math.Pi / 180.0)
-var planeLength = math32.Tan(fovRadians / 2.0)
-
-func renderFloorAndCeiling(screenImage *ebiten.Image) {
-	textureWidth, textureHeight := wallImg.Bounds().Dx(), wallImg.Bounds().Dy()
-	screenWidth, screenHeight := screenImage.Size()
-	posX, posY := float32(player.pos.X), float32(player.pos.Y)
-	dir := angleToXY(player.angle, 1)
-
-	// Use the same planeX and planeY as in wall rendering
-	planeX := -dir.Y * planeLength
-	planeY := dir.X * planeLength
-
-	posZ := 0.5 * float32(screenHeight)
-
-	for y := screenHeight / 2; y < screenHeight; y++ {
-		p := y - screenHeight/2
-		if p == 0 {
-			continue // Avoid division by zero
-		}
-
-		rowDistance := -posZ / float32(p)
-
-		// Leftmost and rightmost ray directions
-		rayDirX0 := dir.X + planeX*(-1)
-		rayDirY0 := dir.Y + planeY*(-1)
-		rayDirX1 := dir.X + planeX*1
-		rayDirY1 := dir.Y + planeY*1
-
-		// Step size per screen pixel
-		floorStepX := (rayDirX1 - rayDirX0) / float32(screenWidth)
-		floorStepY := (rayDirY1 - rayDirY0) / float32(screenWidth)
+var FOVDeg float32 = 90
 
-		// Starting position
-		floorX := posX + rowDistance*rayDirX0
-		floorY := posY + rowDistance*rayDirY0
+// Sized by resizeFrame
+var view = raycast.NewCamera(FOVDeg, screenWidth)
 
-		for x := 0; x < screenWidth; x++ {
-			// The cell coordinates
-			cellX := int(floorX)
-			cellY := int(floorY)
-
-			// Texture coordinates
-			tx := int((floorX-float32(cellX))*float32(textureWidth)) % textureWidth
-			ty := int((floorY-float32(cellY))*float32(textureHeight)) % textureHeight
+var (
+	floorTex, ceilingTex *raycast.Texture
+	framePixels          []byte               // Reused every frame, premultiplied RGBA, sized by resizeFrame
+	sceneFrame           raycast.Frame        // Reused every frame, set by aimScene
+	spriteViews          []raycast.SpriteView // Backing for sceneFrame.Sprites, reused every frame
+)
 
-			if tx < 0 {
-				tx += textureWidth
-			}
-			if ty < 0 {
-				ty += textureHeight
-			}
+// Set up the frame to render from where the player stands
+func aimScene() {
+	sceneFrame = raycast.Frame{
+		Pixels: framePixels, Width: frameWidth, Height: frameHeight,
+		Pos: player.pos, Angle: player.angle, EyeZ: raycast.EyeZAt(sectors, player.pos), Cam: view,
+		Root: bspData, Doors: doors, Textures: textures, Floor: floorTex, Ceiling: ceilingTex,
+	}
+	sceneFrame.Aim()
+	sceneFrame.Sprites = sceneFrame.ProjectSprites(sprites, sectors, spriteViews)
+	spriteViews = sceneFrame.Sprites
+}
 
-			// Sample the floor and ceiling textures
-			floorColor := wallImg.At(tx, ty)
-			ceilingColor := wallImg.At(tx, ty) // Use ceiling texture if available
+// Cast a ray for every column of the frame into rayList
+func castScene() {
+	pool.run(frameWidth, workSize, castColumns)
+}
 
-			// Set the floor pixel
-			screenImage.Set(x, y, floorColor)
+// Cast the rays for a range of columns into rayList
+func castColumns(start, end int) {
+	for col := start; col < end; col++ {
+		sceneFrame.CastColumn(col, &rayList[col])
+	}
+}
 
-			// Set the ceiling pixel
-			screenImage.Set(x, screenHeight-y-1, ceilingColor)
+// Render a range of floor rows, counted down from the horizon
+func renderFloorRows(start, end int) {
+	for row := start; row < end; row++ {
+		sceneFrame.RenderFloorRow(sceneFrame.Height/2 + row)
+	}
+}
 
-			// Move to the next position
-			floorX += floorStepX * rowDistance
-			floorY += floorStepY * rowDistance
+// Shade the floors and ceilings at other heights, and the walls, masked walls and sprites if they're drawn on the CPU
+func shadeColumns(start, end int) {
+	for col := start; col < end; col++ {
+		sceneFrame.ShadeFlats(&rayList[col])
+		if *cpuWalls {
+			sceneFrame.ShadeWalls(&rayList[col])
 		}
 	}
 }
+
+// Cast the floor and ceiling into the CPU framebuffer, for Draw to upload in one go
+func renderFloorAndCeiling() {
+	// Each floor row also draws the ceiling row mirroring it
+	rows := frameHeight - frameHeight/2
+	pool.run(rows, pool.chunkSize(rows), renderFloorRows)
+}
@@ -6,6 +6,7 @@ require (
 	github.com/chewxy/math32 v1.11.1
 	github.com/hajimehoshi/ebiten/v2 v2.7.9
 	golang.org/x/image v0.20.0
+	level v0.0.0
 )
 
 require (
@@ -16,3 +17,5 @@ require (
 	golang.org/x/sync v0.7.0 // indirect
 	golang.org/x/sys v0.20.0 // indirect
 )
+
+replace level => ../level
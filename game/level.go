@@ -0,0 +1,65 @@
+package main
+
+import (
+	"log"
+	"os"
+	"test/raycast"
+	"time"
+)
+
+// Load the level again after it changes on disk, keeping the current one if it won't load
+func reloadLevel() {
+	lvl, err := raycast.LoadLevel(levelPath)
+	if err != nil {
+		log.Printf("Level not reloaded: %v", err)
+		return
+	}
+
+	// Build the new tree before taking the lock so rendering isn't held up
+	root := raycast.BuildBSPTree(lvl.Walls)
+	raycast.AssignSectors(root, lvl.Sectors)
+	doorList := raycast.NewDoors(lvl.Doors, lvl.Sectors)
+	floor, ceiling := textures.Surfaces(lvl)
+	textures.Check(lvl.Walls)
+
+	renderLock.Lock()
+	walls = lvl.Walls
+	sectors = lvl.Sectors
+	sprites = lvl.Sprites
+	doors = doorList
+	bspData = root
+	automap.relink(lvl.Walls, doorList)
+	floorTex, ceilingTex = floor, ceiling
+	renderLock.Unlock()
+
+	log.Printf("Reloaded %v", levelPath)
+}
+
+// Poll the level file and reload it whenever it is written
+func watchLevel() {
+	var oldModTime time.Time
+	if stat, err := os.Stat(levelPath); err == nil {
+		oldModTime = stat.ModTime()
+	}
+
+	statFailed := false
+	for {
+		time.Sleep(time.Second)
+
+		stat, err := os.Stat(levelPath)
+		if err != nil {
+			// Only report once, the editor may be in the middle of saving
+			if !statFailed {
+				log.Printf("Unable to check %v: %v", levelPath, err)
+				statFailed = true
+			}
+			continue
+		}
+		statFailed = false
+
+		if stat.ModTime() != oldModTime {
+			oldModTime = stat.ModTime()
+			reloadLevel()
+		}
+	}
+}
@@ -1,31 +1,27 @@
 package main
 
 import (
-	"image"
+	"flag"
+	"level"
 	"log"
 	"math"
 	"os"
-	"runtime"
-	"strconv"
-	"strings"
-	"time"
+	"test/raycast"
 
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 )
 
 const (
-	screenWidth  = 1280
+	screenWidth  = 1280 // Starting window size
 	screenHeight = 720
-	scaleDiv     = 20
 	levelPath    = "../level1.txt"
-	spriteFile   = "test.png"
 )
 
 var (
 	walls   = []line32{}
-	wallImg *ebiten.Image
-	bspData *BSPNode
+	sectors []level.Sector
+	sprites []level.Sprite
+	bspData *raycast.BSPNode
 )
 
 func (g *Game) Layout(w, h int) (int, int) {
@@ -33,94 +29,64 @@ func (g *Game) Layout(w, h int) (int, int) {
 }
 
 var (
-	textureBounds image.Rectangle
-	textureWidth,
-	textureHeight int
-	textureRepeatDistance float32 = 1.0
-	workSize              int     = 32
-	rayList               [screenWidth]renderData
+	workSize int                  = 32 // Columns per render job, set by resizeFrame
+	rayList  []raycast.RenderData      // One per frame column, sized by resizeFrame
 )
 
 func main() {
+	flag.Parse()
+	if err := checkMinimapCorner(*minimapCorner); err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	pool = newWorkerPool(*workerCount)
+
 	player = playerData{
-		pos: pos32{X: 3, Y: 3}, angle: math.Pi / 2,
+		angle: -math.Pi / 2,
 	}
 
 	ebiten.SetVsyncEnabled(false)
 	ebiten.SetWindowSize(screenWidth, screenHeight)
 	ebiten.SetWindowTitle("Raycaster with vectors and BSP")
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
 
-	readVecs()
-
-	bspData = buildBSPTree(walls)
+	//Load textures
+	textures = raycast.LoadTextures(textureDir)
 
-	//Update level if written
-	go func() {
-		var oldModTime time.Time
-		for {
-			time.Sleep(time.Second)
-			stat, _ := os.Stat(levelPath)
-			if stat.ModTime() != oldModTime {
-				oldModTime = stat.ModTime()
-				readVecs()
-			}
-		}
-	}()
-
-	//Load sprite
-	var err error
-	wallImg, _, err = ebitenutil.NewImageFromFile(spriteFile)
+	lvl, err := raycast.LoadLevel(levelPath)
 	if err != nil {
 		log.Fatalln(err.Error())
 	}
+	walls = lvl.Walls
+	sectors = lvl.Sectors
+	sprites = lvl.Sprites
+	doors = raycast.NewDoors(lvl.Doors, sectors)
+	player.pos = lvl.Start
+	floorTex, ceilingTex = textures.Surfaces(lvl)
+	textures.Check(walls)
+
+	bspData = raycast.BuildBSPTree(walls)
+	raycast.AssignSectors(bspData, sectors)
+
+	// Carry on the automap from last time
+	if err := automap.load(*savePath, levelPath); err != nil && !os.IsNotExist(err) {
+		log.Printf("Automap not restored: %v", err)
+	}
+	automap.relink(walls, doors)
 
-	// Precompute texture width and height (cached outside the loop in the main render function)
-	textureBounds = wallImg.Bounds()
-	textureWidth = textureBounds.Dx()
-	textureHeight = textureBounds.Dy()
-	workSize = int(math.Round(float64(screenWidth)/float64(runtime.NumCPU()))) / 2
+	//Update level if written
+	go watchLevel()
 
 	//Start game
-	if err := ebiten.RunGame(&Game{}); err != nil {
-		panic(err)
-	}
-}
-
-func readVecs() {
+	err = ebiten.RunGame(&Game{})
 
-	data, err := os.ReadFile(levelPath)
-	if err != nil {
-		log.Fatalln("Unable to read " + levelPath)
+	renderLock.Lock()
+	if err := automap.save(*savePath, levelPath); err != nil {
+		log.Printf("Automap not saved: %v", err)
 	}
+	renderLock.Unlock()
 
-	tmp := []line32{}
-	text := string(data)
-	lines := strings.Split(text, "\n")
-
-	for l, line := range lines {
-		if l == 0 {
-			args := strings.Split(line, ",")
-			if len(args) != 2 {
-				continue
-			}
-			x1, _ := strconv.ParseFloat(args[0], 32)
-			y1, _ := strconv.ParseFloat(args[1], 32)
-			player.pos = pos32{X: float32(x1) / scaleDiv, Y: float32(y1) / scaleDiv}
-			continue
-		}
-		args := strings.Split(line, ",")
-		if len(args) != 4 {
-			continue
-		}
-		x1, _ := strconv.ParseFloat(args[0], 32)
-		y1, _ := strconv.ParseFloat(args[1], 32)
-		x2, _ := strconv.ParseFloat(args[2], 32)
-		y2, _ := strconv.ParseFloat(args[3], 32)
-
-		tmp = append(tmp, line32{X1: float32(x1) / scaleDiv, Y1: float32(y1) / scaleDiv, X2: float32(x2) / scaleDiv, Y2: float32(y2) / scaleDiv})
+	if err != nil {
+		panic(err)
 	}
-
-	renderLock.Lock()
-	walls = tmp
-	defer renderLock.Unlock()
 }
@@ -1,100 +1,193 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"image/color"
+	"maps"
+	"slices"
+	"strings"
+	"test/raycast"
+
 	"github.com/chewxy/math32"
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/vector"
 	"golang.org/x/image/colornames"
 )
 
+// Minimap settings, the keys change zoom and rotation as the game runs
+var (
+	minimapRadius = flag.Float64("minimap-radius", 90, "minimap radius in pixels")
+	minimapZoom   = flag.Float64("minimap-zoom", 3, "minimap scale in pixels per world unit")
+	minimapCorner = flag.String("minimap-corner", "top-left", "screen corner for the minimap: top-left, top-right, bottom-left or bottom-right")
+	minimapRotate = flag.Bool("minimap-rotate", false, "turn the minimap with the player instead of keeping north up")
+)
+
 const (
-	miniMapSize   = 100
-	miniMapRadius = 30
+	minimapMargin   = 20 // Pixels between the minimap and the screen edges
+	minimapZoomStep = 1.25
+	minimapMinZoom  = 0.5
+	minimapMaxZoom  = 50
+	coneColumnStep  = 4 // Columns skipped between the ray hits the view cone is drawn through
 )
 
-// Render a clipped wall on the minimap, ensuring it stays within the minimap radius
-func renderClippedWallOnMinimap(wall line32, playerX, playerY int, screen *ebiten.Image) {
-	// Translate wall coordinates relative to player position
-	dx1 := (wall.X1) - (player.pos.X)
-	dy1 := (wall.Y1) - (player.pos.Y)
-	dx2 := (wall.X2) - (player.pos.X)
-	dy2 := (wall.Y2) - (player.pos.Y)
-
-	// Scale wall coordinates to minimap size based on miniMapRadius
-	x1 := float32(playerX) + dx1*(miniMapSize/miniMapRadius)
-	y1 := float32(playerY) + dy1*(miniMapSize/miniMapRadius)
-	x2 := float32(playerX) + dx2*(miniMapSize/miniMapRadius)
-	y2 := float32(playerY) + dy2*(miniMapSize/miniMapRadius)
-
-	// Clip the wall to ensure it's within the minimap radius
-	if !clipLineToCircle(&x1, &y1, &x2, &y2, float32(playerX), float32(playerY), miniMapRadius*(miniMapSize/miniMapRadius)) {
-		return // Skip if the wall is entirely outside the minimap radius
+// Screen corners the minimap can sit in, as which sides it's against
+var minimapCorners = map[string]struct{ right, bottom bool }{
+	"top-left":     {false, false},
+	"top-right":    {true, false},
+	"bottom-left":  {false, true},
+	"bottom-right": {true, true},
+}
+
+var (
+	fillImage    *ebiten.Image // A white pixel to fill shapes on the maps with, made on first use
+	fillVertices []ebiten.Vertex
+	fillIndices  []uint16
+	conePoints   []pos32
+)
+
+var coneColor = color.NRGBA{R: 255, G: 255, A: 64}
+
+// Zoom the minimap with , and . and toggle its rotation with n
+func updateMinimap() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyComma) {
+		*minimapZoom = max(*minimapZoom/minimapZoomStep, minimapMinZoom)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyPeriod) {
+		*minimapZoom = min(*minimapZoom*minimapZoomStep, minimapMaxZoom)
 	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyN) {
+		*minimapRotate = !*minimapRotate
+	}
+}
 
-	// Draw the clipped wall line on the minimap
-	vector.StrokeLine(screen, x1, y1, x2, y2, 1, colornames.Teal, false)
+// Check the minimap corner flag names one of the corners
+func checkMinimapCorner(corner string) error {
+	if _, ok := minimapCorners[corner]; !ok {
+		names := slices.Sorted(maps.Keys(minimapCorners))
+		return fmt.Errorf("unknown minimap corner %q, want one of %v", corner, strings.Join(names, ", "))
+	}
+	return nil
 }
 
-// Traverse BSP and render walls within minimap radius
-func traverseBSPForMinimap(node *BSPNode, playerX, playerY int, screen *ebiten.Image) {
+// Middle of the minimap on a screen of a size
+func minimapCentre(width, height int) (x, y float32) {
+	corner := minimapCorners[*minimapCorner]
+	radius := float32(*minimapRadius)
+	x, y = minimapMargin+radius, minimapMargin+radius
+	if corner.right {
+		x = float32(width) - x
+	}
+	if corner.bottom {
+		y = float32(height) - y
+	}
+	return x, y
+}
+
+// Traverse BSP and render walls that come within reach of the middle of the map
+func traverseBSPForMinimap(node *raycast.BSPNode, view mapView, reach float32, screen *ebiten.Image) {
 	if node == nil {
 		return
 	}
 
-	// Calculate the distance to both wall endpoints using the player's position
-	distToWall1 := calculateDistance((node.wall.X1), (node.wall.Y1), (player.pos.X), (player.pos.Y))
-	distToWall2 := calculateDistance((node.wall.X2), (node.wall.Y2), (player.pos.X), (player.pos.Y))
-
-	// Check if either endpoint is within the minimap radius, or if the wall intersects the radius
-	if distToWall1 <= miniMapRadius || distToWall2 <= miniMapRadius || wallIntersectsMinimap(node.wall) {
-		// Clip the wall if needed and render the portion inside the minimap radius
-		renderClippedWallOnMinimap(node.wall, playerX, playerY, screen)
+	if away := raycast.SubXY(raycast.ClosestPointOnSegment(view.centre, node.Wall), view.centre); raycast.DotXY(away, away) <= reach*reach {
+		renderClippedWallOnMinimap(node.Wall, view, screen, colornames.Teal)
 	}
 
 	// Recursively traverse the front and back subtrees
-	traverseBSPForMinimap(node.front, playerX, playerY, screen)
-	traverseBSPForMinimap(node.back, playerX, playerY, screen)
+	traverseBSPForMinimap(node.Front, view, reach, screen)
+	traverseBSPForMinimap(node.Back, view, reach, screen)
+}
+
+// Render a clipped wall on the minimap, ensuring it stays within the minimap radius
+func renderClippedWallOnMinimap(wall line32, view mapView, screen *ebiten.Image, clr color.Color) {
+	x1, y1 := view.project(pos32{X: wall.X1, Y: wall.Y1})
+	x2, y2 := view.project(pos32{X: wall.X2, Y: wall.Y2})
+	if !clipLineToCircle(&x1, &y1, &x2, &y2, view.midX, view.midY, float32(*minimapRadius)) {
+		return // Skip if the wall is entirely outside the minimap radius
+	}
+	vector.StrokeLine(screen, x1, y1, x2, y2, 1, clr, false)
+}
+
+// Points where the view cone meets the walls, from the rays cast for the frame, no farther than reach.
+// Every step-th column is used, and the last, and the points are appended to buf.
+func viewCone(columns []raycast.RenderData, pos pos32, reach float32, step int, buf []pos32) []pos32 {
+	points := buf[:0]
+	for col := 0; col < len(columns); col += step {
+		if col+step >= len(columns) {
+			col = len(columns) - 1
+		}
+		data := &columns[col]
+
+		// Depths are along the view, the rays of the outer columns are longer than one unit
+		depth := reach / math32.Sqrt(raycast.DotXY(data.RayDir, data.RayDir))
+		if wall, blocked := data.BlockedAt(); blocked {
+			depth = min(depth, wall)
+		}
+		points = append(points, raycast.AddXY(pos, raycast.ScaleXY(data.RayDir, depth)))
+	}
+	return points
 }
 
-// Render the minimap, ensuring it fits fully on the screen
+// Render the minimap in its corner, turned with the player if it's set to
 func renderMinimap(screen *ebiten.Image) {
-	// Calculate the center of the minimap, based on screenWidth and miniMapSize
-	miniMapCenterX := (miniMapSize + 10)
-	miniMapCenterY := (miniMapSize + 10)
+	bounds := screen.Bounds()
+	midX, midY := minimapCentre(bounds.Dx(), bounds.Dy())
+	radius := float32(*minimapRadius)
+	view := newMapView(player.pos, player.angle, *minimapRotate, float32(*minimapZoom), midX, midY)
+	reach := radius / view.zoom
 
-	// Calculate the top-left corner of the minimap
-	miniMapTopLeftX := miniMapCenterX - miniMapSize/2
-	miniMapTopLeftY := miniMapCenterY - miniMapSize/2
+	vector.DrawFilledCircle(screen, midX, midY, radius, color.NRGBA{A: 128}, true)
 
-	// Precalculate player's position within the minimap (center of the minimap)
-	playerX := miniMapTopLeftX + miniMapSize/2
-	playerY := miniMapTopLeftY + miniMapSize/2
+	// Traverse the BSP tree and render walls within the minimap's clipping radius, and the doors
+	traverseBSPForMinimap(bspData, view, reach, screen)
+	for _, d := range doors {
+		if d.Solid() {
+			renderClippedWallOnMinimap(d.Node.Wall, view, screen, colornames.Orange)
+		}
+	}
 
-	// Traverse the BSP tree and render walls within the minimap's clipping radius
-	traverseBSPForMinimap(bspData, playerX, playerY, screen)
+	// The view cone, as far as this frame's rays reached
+	conePoints = viewCone(rayList, player.pos, reach, coneColumnStep, conePoints)
+	fillFan(screen, view, player.pos, conePoints, coneColor)
+	vector.StrokeCircle(screen, midX, midY, radius, 1, colornames.Teal, true)
 
 	// Draw the player as a circle in the center of the minimap
-	vector.DrawFilledCircle(screen, float32(playerX), float32(playerY), 5, colornames.Yellow, false)
-
-	// Optionally, draw the player's facing direction on the minimap
-	facingX := float32(playerX) - (math32.Cos(player.angle))*10
-	facingY := float32(playerY) - (math32.Sin(player.angle))*10
-	vector.StrokeLine(screen, float32(playerX), float32(playerY), facingX, facingY, 2, colornames.Red, false)
-}
+	vector.DrawFilledCircle(screen, midX, midY, 5, colornames.Yellow, false)
 
-// Calculate the 2D Euclidean distance between two points
-func calculateDistance(x1, y1, x2, y2 float32) float32 {
-	return (math32.Sqrt(((x2-x1)*(x2-x1) + (y2-y1)*(y2-y1))))
+	// And the way they face, the same way the camera looks
+	facingX, facingY := view.project(raycast.AddXY(player.pos, raycast.AngleToXY(player.angle, 10/view.zoom)))
+	vector.StrokeLine(screen, midX, midY, facingX, facingY, 2, colornames.Red, false)
 }
 
-// Check if a wall intersects the minimap radius
-func wallIntersectsMinimap(wall line32) bool {
-	// Calculate distances from both endpoints to the player position (minimap center)
-	dist1 := calculateDistance((wall.X1), (wall.Y1), (player.pos.X), (player.pos.Y))
-	dist2 := calculateDistance((wall.X2), (wall.Y2), (player.pos.X), (player.pos.Y))
+// Fill a fan of triangles from a centre point through the points around it, in world units.
+// Convex polygons can be filled from their first corner.
+func fillFan(screen *ebiten.Image, view mapView, centre pos32, points []pos32, clr color.NRGBA) {
+	if len(points) < 2 {
+		return
+	}
+	if fillImage == nil {
+		fillImage = ebiten.NewImage(1, 1)
+		fillImage.Fill(color.White)
+	}
 
-	// Check if one endpoint is inside the minimap radius and the other is outside
-	return (dist1 <= miniMapRadius && dist2 > miniMapRadius) || (dist2 <= miniMapRadius && dist1 > miniMapRadius)
+	vertex := func(p pos32) ebiten.Vertex {
+		x, y := view.project(p)
+		return ebiten.Vertex{
+			DstX: x, DstY: y, SrcX: 0.5, SrcY: 0.5,
+			ColorR: float32(clr.R) / 255, ColorG: float32(clr.G) / 255, ColorB: float32(clr.B) / 255, ColorA: float32(clr.A) / 255,
+		}
+	}
+	fillVertices = append(fillVertices[:0], vertex(centre))
+	fillIndices = fillIndices[:0]
+	for i, p := range points {
+		fillVertices = append(fillVertices, vertex(p))
+		if i > 0 {
+			fillIndices = append(fillIndices, 0, uint16(i), uint16(i+1))
+		}
+	}
+	screen.DrawTriangles(fillVertices, fillIndices, fillImage, &ebiten.DrawTrianglesOptions{})
 }
 
 // Clip a line to a circle and modify the endpoints to keep them within the circle
@@ -114,9 +207,12 @@ func clipLineToCircle(x1, y1, x2, y2 *float32, cx, cy, r float32) bool {
 		return true
 	}
 
-	// If both points are outside the circle, return false (skip this line)
+	// If both points are outside the circle, skip the line unless it passes through the circle
 	if dist1 > r && dist2 > r {
-		return false
+		closest := raycast.ClosestPointOnSegment(pos32{X: cx, Y: cy}, line32{X1: *x1, Y1: *y1, X2: *x2, Y2: *y2})
+		if calculateDistance(closest.X, closest.Y, cx, cy) >= r {
+			return false
+		}
 	}
 
 	// Normalize the direction vector from point 1 to point 2
@@ -139,6 +235,11 @@ func clipLineToCircle(x1, y1, x2, y2 *float32, cx, cy, r float32) bool {
 	return true
 }
 
+// Calculate the 2D Euclidean distance between two points
+func calculateDistance(x1, y1, x2, y2 float32) float32 {
+	return (math32.Sqrt(((x2-x1)*(x2-x1) + (y2-y1)*(y2-y1))))
+}
+
 // Calculate the intersection of a line with a circle
 func intersectWithCircle(x, y, dx, dy, cx, cy, r float32) (float32, float32) {
 	// Translate the line's starting point so the circle is centered at the origin
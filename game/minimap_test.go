@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"test/raycast"
+	"testing"
+
+	"github.com/chewxy/math32"
+)
+
+func TestViewCone(t *testing.T) {
+	lvl, root, list := doorTestLevel(t)
+	columns := castTestColumns(lvl, root, list, lvl.Start)
+
+	// Looking at the dividing wall 5 away, the cone ends on it
+	points := viewCone(columns, lvl.Start, 100, 4, nil)
+	if len(points) != testFrameWidth/4 {
+		t.Errorf("%v points, want one every 4 columns and the last", len(points))
+	}
+	for i, p := range points {
+		if math32.Abs(p.Y-10) > 0.001 {
+			t.Errorf("point %v at %v, want on the dividing wall", i, p)
+		}
+	}
+	if last := points[len(points)-1]; math32.Abs(last.X-lvl.Start.X-5) > 0.05 {
+		t.Errorf("last point %v, want at the right edge of the view", last)
+	}
+
+	// Closer in than the wall, the cone is cut off at the minimap's reach
+	for i, p := range viewCone(columns, lvl.Start, 2, 4, points) {
+		if away := raycast.SubXY(p, lvl.Start); math32.Abs(math32.Sqrt(raycast.DotXY(away, away))-2) > 0.001 {
+			t.Errorf("point %v at %v, want 2 from the player", i, p)
+		}
+	}
+}
+
+func TestMinimapPlacement(t *testing.T) {
+	defer func(corner string) { *minimapCorner = corner }(*minimapCorner)
+
+	radius := float32(*minimapRadius)
+	tests := []struct {
+		corner string
+		x, y   float32
+	}{
+		{"top-left", minimapMargin + radius, minimapMargin + radius},
+		{"top-right", 1000 - minimapMargin - radius, minimapMargin + radius},
+		{"bottom-left", minimapMargin + radius, 500 - minimapMargin - radius},
+		{"bottom-right", 1000 - minimapMargin - radius, 500 - minimapMargin - radius},
+	}
+	for _, tt := range tests {
+		if err := checkMinimapCorner(tt.corner); err != nil {
+			t.Errorf("%v: %v", tt.corner, err)
+		}
+		*minimapCorner = tt.corner
+		if x, y := minimapCentre(1000, 500); x != tt.x || y != tt.y {
+			t.Errorf("%v: minimap centred at %v,%v, want %v,%v", tt.corner, x, y, tt.x, tt.y)
+		}
+	}
+
+	err := checkMinimapCorner("middle")
+	if err == nil || !strings.Contains(err.Error(), "bottom-right, top-left, top-right") {
+		t.Errorf("unknown corner gave %v, want an error listing the corners", err)
+	}
+}
+
+func TestMinimapRotates(t *testing.T) {
+	pos := pos32{X: 3, Y: 4}
+	for _, angle := range []float32{0, 1, -2.5} {
+		view := newMapView(pos, angle, true, 3, 100, 100)
+		x, y := view.project(raycast.AddXY(pos, raycast.AngleToXY(angle, 10)))
+		if math32.Abs(x-100) > 0.001 || math32.Abs(y-70) > 0.001 {
+			t.Errorf("angle %v: facing drawn at %v,%v, want straight up", angle, x, y)
+		}
+	}
+}
+
+func TestClipLineToCircle(t *testing.T) {
+	// Both ends outside but passing through the middle, cut to the circle
+	x1, y1, x2, y2 := float32(-10), float32(0), float32(10), float32(0)
+	if !clipLineToCircle(&x1, &y1, &x2, &y2, 0, 0, 5) {
+		t.Fatal("line through the circle was skipped")
+	}
+	if math32.Abs(x1+5) > 0.001 || math32.Abs(x2-5) > 0.001 {
+		t.Errorf("clipped to %v..%v, want -5..5", x1, x2)
+	}
+
+	// Both ends outside and missing it
+	x1, y1, x2, y2 = -10, 6, 10, 6
+	if clipLineToCircle(&x1, &y1, &x2, &y2, 0, 0, 5) {
+		t.Error("line missing the circle was drawn")
+	}
+}
@@ -1,83 +1,139 @@
 package main
 
 import (
+	"flag"
+	"test/raycast"
+
 	"github.com/chewxy/math32"
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
 )
 
 const (
-	moveSpeed  = 0.02
-	turnSpeed  = 0.05
-	playerSize = 0.5
+	moveSpeed = 0.02
+	turnSpeed = 0.05
+
+	friction  = 0.009
+	maxSpeed  = 0.1
+	runFactor = 2 // Top speed is this many times faster while shift is held
+)
 
-	friction = 0.009
-	maxSpeed = 0.1
+// Mouse look settings. Clicking in the window captures the cursor to turn with, and escape lets it go.
+var (
+	mouseLook        = flag.Bool("mouse-look", true, "turn with the mouse once the window is clicked")
+	mouseSensitivity = flag.Float64("mouse-sensitivity", 0.15, "degrees turned per pixel the mouse moves")
 )
 
-var player playerData
+var (
+	player playerData
+
+	mouseX     int  // Cursor position last tick, to turn by how far it moved
+	mouseReady bool // Whether mouseX has been read since the cursor was captured
+)
 
 func (g *Game) Update() error {
-	if ebiten.IsKeyPressed(ebiten.KeyS) {
-		if player.speed < maxSpeed {
-			player.speed += moveSpeed
-			if player.speed > maxSpeed {
-				player.speed = maxSpeed
-			}
-		}
-	} else if ebiten.IsKeyPressed(ebiten.KeyW) {
-		if player.speed > -maxSpeed {
-			player.speed -= moveSpeed
-			if player.speed < -maxSpeed {
-				player.speed = -maxSpeed
-			}
+	forward, right, run := movementKeys()
+	player.velocity = accelerate(player.velocity, player.angle, forward, right, run)
+
+	// Arrows turn, unless they're panning the automap
+	if !automap.show {
+		if ebiten.IsKeyPressed(ebiten.KeyArrowLeft) {
+			player.angle -= turnSpeed
 		}
-	} else {
-		if player.speed > 0 {
-			if player.speed < friction {
-				player.speed = 0
-			} else {
-				player.speed -= friction
-			}
-		} else if player.speed < 0 {
-			if player.speed > -friction {
-				player.speed = 0
-			} else {
-				player.speed += friction
-			}
+		if ebiten.IsKeyPressed(ebiten.KeyArrowRight) {
+			player.angle += turnSpeed
 		}
 	}
+	updateMouseLook()
 
-	//do rotation speed too
-	if ebiten.IsKeyPressed(ebiten.KeyA) {
-		player.angle -= turnSpeed
+	updateFullscreen()
+	updateMinimap()
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyBracketLeft) {
+		setFOV(FOVDeg - fovStepDeg)
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyD) {
-		player.angle += turnSpeed
+	if inpututil.IsKeyJustPressed(ebiten.KeyBracketRight) {
+		setFOV(FOVDeg + fovStepDeg)
 	}
 
-	player.velocity = angleToXY(player.angle, player.speed)
-
-	player.pos = addXY(player.pos, player.velocity)
+	// The level may be swapped by a reload, and the doors move, so only touch them under the lock
+	renderLock.Lock()
+	sprites = pickUpKeys(sprites, player.pos)
+	updateDoors(bspData, doors, player.pos, player.angle, inpututil.IsKeyJustPressed(ebiten.KeyE), 1/float32(ebiten.TPS()))
+	player.pos = raycast.MoveAndSlide(bspData, doors, player.pos, player.velocity, raycast.PlayerRadius)
+	automap.update()
+	updateBSPOverlay()
+	automap.record(player.pos)
+	renderLock.Unlock()
 	return nil
 }
 
-func clipMovement(movement, collisionNormal pos32) pos32 {
-	// Normalize the collision normal
-	normal := normalizeXY(collisionNormal)
+// Which way the held keys move the player: w and s forward and back, a and d strafing, and shift to run
+func movementKeys() (forward, right float32, run bool) {
+	if ebiten.IsKeyPressed(ebiten.KeyW) {
+		forward++
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyS) {
+		forward--
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyD) {
+		right++
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyA) {
+		right--
+	}
+	return forward, right, ebiten.IsKeyPressed(ebiten.KeyShift)
+}
 
-	// Project movement onto the normal (component to block)
-	projection := scaleXY(normal, dotXY(movement, normal))
+// Speed a velocity up towards the way the player is moving, relative to the way they face, or slow it to a stop.
+// Moving diagonally is no faster than straight ahead.
+func accelerate(velocity pos32, angle, forward, right float32, run bool) pos32 {
+	wish := raycast.AddXY(raycast.AngleToXY(angle, forward), raycast.AngleToXY(angle+math32.Pi/2, right))
 
-	// Subtract projection from the movement to get the clipped movement
-	clippedMovement := subXY(movement, projection)
+	var target pos32
+	rate := float32(friction)
+	if wish != (pos32{}) {
+		top := float32(maxSpeed)
+		if run {
+			top *= runFactor
+		}
+		target = raycast.ScaleXY(raycast.NormalizeXY(wish), top)
+		rate = moveSpeed
+	}
 
-	return clippedMovement
+	change := raycast.SubXY(target, velocity)
+	if length := math32.Sqrt(raycast.DotXY(change, change)); length > rate {
+		change = raycast.ScaleXY(change, rate/length)
+	}
+	return raycast.AddXY(velocity, change)
+}
+
+// Turn the player by how far the captured cursor moved since last tick
+func updateMouseLook() {
+	if !*mouseLook {
+		return
+	}
+	if ebiten.CursorMode() != ebiten.CursorModeCaptured {
+		mouseReady = false
+		if ebiten.IsFocused() && inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+			ebiten.SetCursorMode(ebiten.CursorModeCaptured)
+		}
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		ebiten.SetCursorMode(ebiten.CursorModeVisible)
+		return
+	}
+
+	// The cursor can jump as it's captured, so the first position is only a starting point
+	x, _ := ebiten.CursorPosition()
+	if mouseReady {
+		player.angle += mouseTurn(x-mouseX, float32(*mouseSensitivity))
+	}
+	mouseX, mouseReady = x, true
 }
 
-// Function to convert an angle in radians to a velocity vector with momentum
-func angleToXY(angle float32, magnitude float32) pos32 {
-	// Calculate X and Y components using trigonometry
-	vx := magnitude * math32.Cos(angle)
-	vy := magnitude * math32.Sin(angle)
-	return pos32{X: vx, Y: vy}
+// Radians turned for the cursor moving dx pixels, at a sensitivity in degrees per pixel
+func mouseTurn(dx int, sensitivity float32) float32 {
+	return float32(dx) * sensitivity * math32.Pi / 180
 }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"test/raycast"
+	"testing"
+
+	"github.com/chewxy/math32"
+)
+
+// Velocity after holding the keys for a number of ticks from a standstill
+func holdKeys(angle, forward, right float32, run bool, ticks int) pos32 {
+	var velocity pos32
+	for range ticks {
+		velocity = accelerate(velocity, angle, forward, right, run)
+	}
+	return velocity
+}
+
+func speedOf(velocity pos32) float32 {
+	return math32.Sqrt(raycast.DotXY(velocity, velocity))
+}
+
+func TestAccelerateDiagonalNoFaster(t *testing.T) {
+	const eps = 1e-5
+	straight := holdKeys(0, 1, 0, false, 100)
+	diagonal := holdKeys(0, 1, 1, false, 100)
+
+	if math32.Abs(speedOf(straight)-maxSpeed) > eps {
+		t.Errorf("top speed straight ahead is %v, want %v", speedOf(straight), maxSpeed)
+	}
+	if math32.Abs(speedOf(diagonal)-maxSpeed) > eps {
+		t.Errorf("top speed diagonally is %v, want %v", speedOf(diagonal), maxSpeed)
+	}
+	if running := holdKeys(0, 1, 1, true, 100); math32.Abs(speedOf(running)-maxSpeed*runFactor) > eps {
+		t.Errorf("top running speed is %v, want %v", speedOf(running), maxSpeed*runFactor)
+	}
+}
+
+func TestAccelerateRelativeToFacing(t *testing.T) {
+	const eps = 1e-5
+	// Facing up the screen, -Y, forward is up and right is +X
+	angle := -math32.Pi / 2
+
+	if v := holdKeys(angle, 1, 0, false, 100); math32.Abs(v.X) > eps || v.Y >= 0 {
+		t.Errorf("w moves %v, want up the screen", v)
+	}
+	if v := holdKeys(angle, -1, 0, false, 100); math32.Abs(v.X) > eps || v.Y <= 0 {
+		t.Errorf("s moves %v, want down the screen", v)
+	}
+	if v := holdKeys(angle, 0, 1, false, 100); v.X <= 0 || math32.Abs(v.Y) > eps {
+		t.Errorf("d moves %v, want to the right", v)
+	}
+	if v := holdKeys(angle, 0, -1, false, 100); v.X >= 0 || math32.Abs(v.Y) > eps {
+		t.Errorf("a moves %v, want to the left", v)
+	}
+}
+
+func TestAccelerateStops(t *testing.T) {
+	velocity := holdKeys(1, 1, -1, true, 100)
+	for range 100 {
+		velocity = accelerate(velocity, 1, 0, 0, false)
+	}
+	if velocity != (pos32{}) {
+		t.Errorf("still moving at %v after letting go", velocity)
+	}
+}
+
+func TestMouseTurn(t *testing.T) {
+	if got := mouseTurn(100, 0.9); math32.Abs(got-math32.Pi/2) > 1e-5 {
+		t.Errorf("100 pixels at 0.9 degrees a pixel turns %v radians, want a quarter turn", got)
+	}
+	if got := mouseTurn(-10, 0.15); got >= 0 {
+		t.Errorf("moving the mouse left turns %v, want left", got)
+	}
+}
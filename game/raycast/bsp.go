@@ -0,0 +1,202 @@
+package raycast
+
+import (
+	"math/rand"
+
+	"github.com/chewxy/math32"
+)
+
+type BSPNode struct {
+	Wall   line32   // The wall that splits the space
+	Front  *BSPNode // The front subspace
+	Back   *BSPNode // The back subspace
+	isLeaf bool     // Whether this node is a leaf node
+	walls  []line32 // Walls in the node (for leaf nodes)
+	Source line32   // The level wall this one was cut from, the same as wall unless it was split
+
+	frontSector, backSector *sector // Sectors either side of the wall, set by AssignSectors
+}
+
+// Distance below which a point is considered to lie on a partition line
+const splitEpsilon = 0.0001
+
+// Which side of a partition line a wall falls on
+const (
+	sideFront = iota
+	sideBack
+	sideSpanning
+)
+
+// Build a BSP tree from a list of walls using the default partition heuristic
+func BuildBSPTree(walls []line32) *BSPNode {
+	root, _ := buildBSPTreeWith(walls, bspConfig)
+	return root
+}
+
+// Build a BSP tree with the given heuristic and report what it produced
+func buildBSPTreeWith(walls []line32, heuristic bspHeuristic) (*BSPNode, BSPStats) {
+	// Zero-length walls can't partition anything, drop them
+	var usable []line32
+	for _, wall := range walls {
+		if wallLength(wall) >= splitEpsilon {
+			usable = append(usable, wall)
+		}
+	}
+
+	stats := BSPStats{}
+	rng := rand.New(rand.NewSource(heuristic.seed))
+	root := buildBSPNode(usable, usable, heuristic, rng, 1, &stats)
+	return root, stats
+}
+
+// Each wall's source is the level wall it was cut from, kept alongside it.
+func buildBSPNode(walls, sources []line32, heuristic bspHeuristic, rng *rand.Rand, depth int, stats *BSPStats) *BSPNode {
+	if len(walls) == 0 {
+		return nil
+	}
+
+	stats.Nodes++
+	stats.Depth = max(stats.Depth, depth)
+
+	// Pick the partitioning wall that best trades splits against balance
+	partitionIndex := choosePartition(walls, heuristic, rng)
+	partitionWall := walls[partitionIndex]
+
+	// Initialize lists for front and back walls
+	var frontWalls, backWalls []line32
+	var frontSources, backSources []line32
+
+	// Classify the remaining walls as either front or back of the partition wall
+	for i, wall := range walls {
+		if i == partitionIndex {
+			continue
+		}
+
+		// Add the wall to the appropriate list
+		switch classifyWall(wall, partitionWall) {
+		case sideFront:
+			frontWalls = append(frontWalls, wall)
+			frontSources = append(frontSources, sources[i])
+		case sideBack:
+			backWalls = append(backWalls, wall)
+			backSources = append(backSources, sources[i])
+		default:
+			//Split walls crossing the partition line
+			frontPart, backPart := splitWall(wall, partitionWall)
+			frontWalls = append(frontWalls, frontPart)
+			backWalls = append(backWalls, backPart)
+			frontSources = append(frontSources, sources[i])
+			backSources = append(backSources, sources[i])
+			stats.Splits++
+		}
+	}
+
+	// Recursively build the BSP tree
+	return &BSPNode{
+		Wall:   partitionWall,
+		Source: sources[partitionIndex],
+		Front:  buildBSPNode(frontWalls, frontSources, heuristic, rng, depth+1, stats),
+		Back:   buildBSPNode(backWalls, backSources, heuristic, rng, depth+1, stats),
+		isLeaf: false,
+	}
+}
+
+// Work out which side of the partition line a wall is on, or if it crosses it
+func classifyWall(wall, partition line32) int {
+	side1 := SideDistance(pos32{X: wall.X1, Y: wall.Y1}, partition)
+	side2 := SideDistance(pos32{X: wall.X2, Y: wall.Y2}, partition)
+
+	// Walls on the partition line go in front if they face the same way
+	if math32.Abs(side1) < splitEpsilon && math32.Abs(side2) < splitEpsilon {
+		if DotXY(movementDirection(wall), movementDirection(partition)) >= 0 {
+			return sideFront
+		}
+		return sideBack
+	}
+
+	if side1 > -splitEpsilon && side2 > -splitEpsilon {
+		return sideFront
+	}
+	if side1 < splitEpsilon && side2 < splitEpsilon {
+		return sideBack
+	}
+	return sideSpanning
+}
+
+// Split a wall where it crosses the partition line.
+// Both parts keep the original direction, and the texture offset of the
+// second part is advanced so the texture continues across the cut.
+func splitWall(wall, partition line32) (frontPart, backPart line32) {
+	side1 := SideDistance(pos32{X: wall.X1, Y: wall.Y1}, partition)
+	side2 := SideDistance(pos32{X: wall.X2, Y: wall.Y2}, partition)
+
+	// Fraction along the wall where it meets the partition line
+	t := side1 / (side1 - side2)
+	hitX := wall.X1 + t*(wall.X2-wall.X1)
+	hitY := wall.Y1 + t*(wall.Y2-wall.Y1)
+
+	firstPart, secondPart := wall, wall
+	firstPart.X2, firstPart.Y2 = hitX, hitY
+	secondPart.X1, secondPart.Y1 = hitX, hitY
+	secondPart.Offset = wall.Offset + t*wallLength(wall)
+
+	if side1 > 0 {
+		return firstPart, secondPart
+	}
+	return secondPart, firstPart
+}
+
+// Signed distance from a point to the partition line, positive in front
+func SideDistance(p pos32, partition line32) float32 {
+	length := wallLength(partition)
+	if length == 0 {
+		return 0
+	}
+	return pointSide(p, partition) / length
+}
+
+// Length of a wall
+func wallLength(wall line32) float32 {
+	return math32.Sqrt((wall.X2-wall.X1)*(wall.X2-wall.X1) + (wall.Y2-wall.Y1)*(wall.Y2-wall.Y1))
+}
+
+// Function to calculate which side of the wall the player is on
+func pointSide(p pos32, wall line32) float32 {
+	return (wall.X2-wall.X1)*(p.Y-wall.Y1) - (wall.Y2-wall.Y1)*(p.X-wall.X1)
+}
+
+// Calculate the distance from the player to a wall.
+// This is the perpendicular distance where the player is alongside the wall, and the distance to the nearer end past it.
+func distanceToWall(wall line32, playerPos pos32) float32 {
+	away := SubXY(playerPos, ClosestPointOnSegment(playerPos, wall))
+	return math32.Sqrt(DotXY(away, away))
+}
+
+var textureRepeatDistance float32 = 1.0
+
+// Position across the wall texture (0-1) for a hit on a wall
+func wallTextureU(wall line32, hitPos pos32) float32 {
+	// Calculate the direction vector for the wall
+	wallDirX := wall.X2 - wall.X1
+	wallDirY := wall.Y2 - wall.Y1
+	wallLength := math32.Sqrt(wallDirX*wallDirX + wallDirY*wallDirY)
+	if wallLength == 0 {
+		return 0
+	}
+
+	// Normalize the direction vector
+	wallDirX /= wallLength
+	wallDirY /= wallLength
+
+	// Calculate the hit position along the wall
+	dx := hitPos.X - wall.X1
+	dy := hitPos.Y - wall.Y1
+	wallHitPosition := (dx*wallDirX + dy*wallDirY) + wall.Offset
+
+	// Wrap at the fixed texture repeat distance
+	wallHitPosition = math32.Mod(wallHitPosition, textureRepeatDistance)
+	if wallHitPosition < 0 {
+		wallHitPosition += textureRepeatDistance
+	}
+	return wallHitPosition / textureRepeatDistance
+}
@@ -0,0 +1,231 @@
+package raycast
+
+import (
+	"level"
+	"slices"
+	"testing"
+
+	"github.com/chewxy/math32"
+)
+
+const (
+	levelPath    = "../../level1.txt" // The level the game starts with
+	textureDir   = "../textures"
+	benchColumns = 1280 // Rays per frame in the benchmarks, one per column of the starting window
+)
+
+// Load the level the game starts with
+func loadTestLevel(t testing.TB) level.Level {
+	lvl, err := LoadLevel(levelPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lvl
+}
+
+// Collect every wall stored in a subtree
+func collectWalls(node *BSPNode, out []line32) []line32 {
+	if node == nil {
+		return out
+	}
+	out = append(out, node.Wall)
+	out = collectWalls(node.Front, out)
+	return collectWalls(node.Back, out)
+}
+
+func TestBuildBSPTreeLevel1Sides(t *testing.T) {
+	root := BuildBSPTree(loadTestLevel(t).Walls)
+	if root == nil {
+		t.Fatal("no tree built from " + levelPath)
+	}
+
+	var check func(node *BSPNode)
+	check = func(node *BSPNode) {
+		if node == nil {
+			return
+		}
+		for _, wall := range collectWalls(node.Front, nil) {
+			if classifyWall(wall, node.Wall) != sideFront {
+				t.Errorf("wall %v is in front of %v but not on its front side", wall, node.Wall)
+			}
+		}
+		for _, wall := range collectWalls(node.Back, nil) {
+			if classifyWall(wall, node.Wall) != sideBack {
+				t.Errorf("wall %v is behind %v but not on its back side", wall, node.Wall)
+			}
+		}
+		check(node.Front)
+		check(node.Back)
+	}
+	check(root)
+}
+
+func TestBuildBSPTreeKeepsWallLength(t *testing.T) {
+	lvl := loadTestLevel(t)
+
+	var want float32
+	for _, wall := range lvl.Walls {
+		want += wallLength(wall)
+	}
+	var got float32
+	for _, wall := range collectWalls(BuildBSPTree(lvl.Walls), nil) {
+		got += wallLength(wall)
+	}
+
+	if math32.Abs(got-want) > 0.01 {
+		t.Errorf("total wall length %v after building tree, want %v", got, want)
+	}
+}
+
+func TestBuildBSPTreeKeepsSources(t *testing.T) {
+	lvl := loadTestLevel(t)
+
+	var check func(node *BSPNode)
+	check = func(node *BSPNode) {
+		if node == nil {
+			return
+		}
+		if !slices.Contains(lvl.Walls, node.Source) {
+			t.Errorf("wall %v comes from %v, which isn't in the level", node.Wall, node.Source)
+		}
+		for _, end := range []pos32{{X: node.Wall.X1, Y: node.Wall.Y1}, {X: node.Wall.X2, Y: node.Wall.Y2}} {
+			if distanceToWall(node.Source, end) > 0.001 {
+				t.Errorf("wall %v isn't part of its source %v", node.Wall, node.Source)
+			}
+		}
+		check(node.Front)
+		check(node.Back)
+	}
+	check(BuildBSPTree(lvl.Walls))
+}
+
+func TestSplitWall(t *testing.T) {
+	partition := line32{X1: 5, Y1: 0, X2: 5, Y2: 10}
+
+	tests := []struct {
+		name      string
+		wall      line32
+		wantFront line32
+		wantBack  line32
+	}{
+		{
+			name:      "left to right",
+			wall:      line32{X1: 0, Y1: 2, X2: 10, Y2: 2},
+			wantFront: line32{X1: 0, Y1: 2, X2: 5, Y2: 2, Offset: 0},
+			wantBack:  line32{X1: 5, Y1: 2, X2: 10, Y2: 2, Offset: 5},
+		},
+		{
+			name:      "right to left",
+			wall:      line32{X1: 10, Y1: 2, X2: 0, Y2: 2},
+			wantFront: line32{X1: 5, Y1: 2, X2: 0, Y2: 2, Offset: 5},
+			wantBack:  line32{X1: 10, Y1: 2, X2: 5, Y2: 2, Offset: 0},
+		},
+		{
+			name:      "already split",
+			wall:      line32{X1: 2, Y1: 4, X2: 8, Y2: 4, Offset: 3},
+			wantFront: line32{X1: 2, Y1: 4, X2: 5, Y2: 4, Offset: 3},
+			wantBack:  line32{X1: 5, Y1: 4, X2: 8, Y2: 4, Offset: 6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if side := classifyWall(tt.wall, partition); side != sideSpanning {
+				t.Fatalf("classifyWall = %v, want spanning", side)
+			}
+			front, back := splitWall(tt.wall, partition)
+			if front != tt.wantFront {
+				t.Errorf("front part = %v, want %v", front, tt.wantFront)
+			}
+			if back != tt.wantBack {
+				t.Errorf("back part = %v, want %v", back, tt.wantBack)
+			}
+		})
+	}
+}
+
+func TestClassifyWallOnPartition(t *testing.T) {
+	partition := line32{X1: 0, Y1: 0, X2: 10, Y2: 0}
+
+	if side := classifyWall(line32{X1: 2, Y1: 0, X2: 4, Y2: 0}, partition); side != sideFront {
+		t.Errorf("same direction collinear wall classified %v, want front", side)
+	}
+	if side := classifyWall(line32{X1: 4, Y1: 0, X2: 2, Y2: 0}, partition); side != sideBack {
+		t.Errorf("opposite direction collinear wall classified %v, want back", side)
+	}
+	if side := classifyWall(line32{X1: 2, Y1: 0, X2: 4, Y2: 3}, partition); side != sideFront {
+		t.Errorf("wall touching partition classified %v, want front", side)
+	}
+}
+
+// Ray directions for every screen column, as renderScene casts them
+func columnRays(angle float32, columns int) []pos32 {
+	cam := NewCamera(90, columns)
+	dir, plane := cam.basis(angle)
+
+	rays := make([]pos32, columns)
+	for col := range rays {
+		rays[col] = cam.columnRay(col, dir, plane)
+	}
+	return rays
+}
+
+// The nearest hit of a ray on any wall, checking every one
+func castRayFull(walls []line32, origin, dir pos32) (float32, bool) {
+	nearest, found := float32(math32.MaxFloat32), false
+	for _, wall := range walls {
+		if dist, _, hit := RayIntersectsSegment(origin, dir, wall); hit && dist < nearest {
+			nearest, found = dist, true
+		}
+	}
+	return nearest, found
+}
+
+func TestCastRayMatchesFull(t *testing.T) {
+	lvl := loadTestLevel(t)
+	root := BuildBSPTree(lvl.Walls)
+	all := collectWalls(root, nil)
+	positions := []pos32{lvl.Start, {X: 23, Y: 38}, {X: 27, Y: 33}, {X: 8, Y: 42}}
+	for _, pos := range positions {
+		for _, angle := range []float32{0, 1, 2.5, 4} {
+			for col, rayDir := range columnRays(angle, 320) {
+				wantDist, wantFound := castRayFull(all, pos, rayDir)
+				hit := CastRay(root, nil, pos, rayDir, math32.MaxFloat32, nil)
+
+				if hit.Found != wantFound || math32.Abs(hit.Dist-wantDist) > 0.001 {
+					t.Errorf("pos %v angle %v col %v: distance %v, want %v", pos, angle, col, hit.Dist, wantDist)
+					continue
+				}
+				if hit.Found && (DotXY(hit.Normal, rayDir) > 0 || math32.Abs(DotXY(hit.Normal, hit.Normal)-1) > 0.001) {
+					t.Errorf("pos %v angle %v col %v: normal %v doesn't face back along the ray", pos, angle, col, hit.Normal)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkCastRay(b *testing.B) {
+	lvl := loadTestLevel(b)
+	root := BuildBSPTree(lvl.Walls)
+	rays := columnRays(math32.Pi/2, benchColumns)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, rayDir := range rays {
+			CastRay(root, nil, lvl.Start, rayDir, math32.MaxFloat32, nil)
+		}
+	}
+}
+
+func BenchmarkCastRayFull(b *testing.B) {
+	lvl := loadTestLevel(b)
+	all := collectWalls(BuildBSPTree(lvl.Walls), nil)
+	rays := columnRays(math32.Pi/2, benchColumns)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, rayDir := range rays {
+			castRayFull(all, lvl.Start, rayDir)
+		}
+	}
+}
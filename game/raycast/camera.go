@@ -0,0 +1,46 @@
+package raycast
+
+import "github.com/chewxy/math32"
+
+// The view into the level, shared by the walls, floor and minimap.
+// Rays go out through a camera plane in front of the player, one per screen column.
+type Camera struct {
+	fovDeg      float32
+	planeLength float32 // Half width of the camera plane, tan(FOV/2)
+	columns     []cameraColumn
+}
+
+// Precomputed ray for one screen column
+type cameraColumn struct {
+	planeX    float32 // Where the ray crosses the camera plane, -1 on the left to 1 on the right
+	rayLength float32 // Length of the ray for one unit of view depth, 1/cos of its angle
+}
+
+// Set up a camera, precomputing the rays for every column
+func NewCamera(fovDeg float32, width int) *Camera {
+	cam := &Camera{
+		fovDeg:      fovDeg,
+		planeLength: math32.Tan(fovDeg * math32.Pi / 180 / 2),
+		columns:     make([]cameraColumn, width),
+	}
+	for col := range cam.columns {
+		planeX := 2*float32(col)/float32(width) - 1
+		offset := planeX * cam.planeLength
+		cam.columns[col] = cameraColumn{planeX: planeX, rayLength: math32.Sqrt(1 + offset*offset)}
+	}
+	return cam
+}
+
+// View direction and camera plane for an angle.
+// The plane is scaled so dir+plane is the ray on the right edge of the screen.
+func (c *Camera) basis(angle float32) (dir, plane pos32) {
+	dir = AngleToXY(angle, 1)
+	plane = pos32{X: -dir.Y * c.planeLength, Y: dir.X * c.planeLength}
+	return dir, plane
+}
+
+// Ray through a column, one unit long along the view direction.
+// Distances along it are the view depth, which doesn't need fisheye correction.
+func (c *Camera) columnRay(col int, dir, plane pos32) pos32 {
+	return AddXY(dir, ScaleXY(plane, c.columns[col].planeX))
+}
@@ -0,0 +1,116 @@
+package raycast
+
+import "github.com/chewxy/math32"
+
+const (
+	playerSize   = 0.5
+	PlayerRadius = playerSize / 2
+
+	// Moves are split into steps no longer than this, so a step can't pass through a wall
+	maxMoveStep = PlayerRadius / 2
+	// Walls a single step may be pushed out of before giving up, for corners
+	collisionIterations = 4
+)
+
+// A wall the player overlaps
+type contact struct {
+	normal pos32   // Unit vector pointing out of the wall
+	depth  float32 // How far the player overlaps the wall
+	found  bool
+}
+
+// Move a circle through the level, sliding along any walls and doors it runs into
+func MoveAndSlide(root *BSPNode, doors []*Door, pos, movement pos32, radius float32) pos32 {
+	length := math32.Sqrt(DotXY(movement, movement))
+	if length == 0 {
+		return pos
+	}
+
+	// Substep fast moves so the player can't tunnel through thin walls
+	steps := int(math32.Ceil(length / maxMoveStep))
+	step := ScaleXY(movement, 1/float32(steps))
+
+	for i := 0; i < steps; i++ {
+		pos = slideStep(root, doors, pos, step, radius)
+	}
+	return pos
+}
+
+// Take one short step, sliding the circle back out of every wall it pushes into
+func slideStep(root *BSPNode, doors []*Door, pos, move pos32, radius float32) pos32 {
+	target := AddXY(pos, move)
+	for i := 0; i < collisionIterations; i++ {
+		hit := findContactWithDoors(root, doors, target, move, radius)
+		if !hit.found {
+			return target
+		}
+
+		// Drop the part of the movement going into the wall, keeping the slide along it,
+		// and back out of the overlap so we end up flush against the wall
+		move = clipMovement(move, hit.normal)
+		target = AddXY(target, ScaleXY(hit.normal, hit.depth))
+	}
+
+	// Still overlapping after the last push, wedged into a corner, stay put
+	if hit := findContactWithDoors(root, doors, target, move, radius); hit.found {
+		return pos
+	}
+	return target
+}
+
+// Find the wall or door the circle overlaps the most
+func findContactWithDoors(root *BSPNode, doors []*Door, pos, move pos32, radius float32) contact {
+	var hit contact
+	findContact(root, pos, move, radius, &hit)
+	for _, d := range doors {
+		if d.Solid() {
+			findContact(&d.Node, pos, move, radius, &hit)
+		}
+	}
+	return hit
+}
+
+// Find the wall the circle overlaps the most.
+// Only the BSP subspaces the circle reaches are searched.
+func findContact(node *BSPNode, pos, move pos32, radius float32, best *contact) {
+	if node == nil {
+		return
+	}
+
+	side := SideDistance(pos, node.Wall)
+	if side > -radius {
+		findContact(node.Front, pos, move, radius, best)
+	}
+	if side < radius {
+		findContact(node.Back, pos, move, radius, best)
+	}
+	if math32.Abs(side) >= radius {
+		return // Too far from the partition line to touch the wall on it
+	}
+	if node.passable() {
+		return // An opening the player can step through
+	}
+
+	closest := ClosestPointOnSegment(pos, node.Wall)
+	away := SubXY(pos, closest)
+	dist := math32.Sqrt(DotXY(away, away))
+	if dist >= radius {
+		return
+	}
+
+	var normal pos32
+	if dist > 0 {
+		normal = ScaleXY(away, 1/dist)
+	} else {
+		// Centred on the wall, push back the way we came
+		wallDir := NormalizeXY(movementDirection(node.Wall))
+		normal = pos32{X: -wallDir.Y, Y: wallDir.X}
+		if DotXY(normal, move) > 0 {
+			normal = ScaleXY(normal, -1)
+		}
+	}
+
+	if depth := radius - dist; !best.found || depth > best.depth {
+		*best = contact{normal: normal, depth: depth, found: true}
+	}
+}
@@ -0,0 +1,148 @@
+package raycast
+
+import (
+	"level"
+	"testing"
+
+	"github.com/chewxy/math32"
+)
+
+// Shortest distance from pos to any wall
+func nearestWallDistance(walls []line32, pos pos32) float32 {
+	var nearest float32 = math32.MaxFloat32
+	for _, wall := range walls {
+		nearest = math32.Min(nearest, distanceToWall(wall, pos))
+	}
+	return nearest
+}
+
+// Whether the path from a to b crosses any wall
+func crossesWall(walls []line32, a, b pos32) bool {
+	move := SubXY(b, a)
+	for _, wall := range walls {
+		if dist, _, ok := RayIntersectsSegment(a, move, wall); ok && dist <= 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMoveAndSlideLevel1Walls(t *testing.T) {
+	lvl := loadTestLevel(t)
+	root := BuildBSPTree(lvl.Walls)
+	const eps = 0.001
+
+	for _, speed := range []float32{0.1, 1, 5} {
+		for i := 0; i < 32; i++ {
+			angle := float32(i) * 2 * math32.Pi / 32
+			pos := lvl.Start
+			// Keep pushing in one direction, then turn slightly so we scrape along walls too
+			for frame := 0; frame < 400; frame++ {
+				move := AngleToXY(angle+float32(frame)*0.01, speed)
+
+				// A slide can round a corner, so check each substep's straight path
+				steps := int(math32.Ceil(speed / maxMoveStep))
+				for step := 0; step < steps; step++ {
+					next := MoveAndSlide(root, nil, pos, ScaleXY(move, 1/float32(steps)), PlayerRadius)
+
+					if crossesWall(lvl.Walls, pos, next) {
+						t.Fatalf("speed %v angle %v frame %v: moved through a wall from %v to %v", speed, angle, frame, pos, next)
+					}
+					if dist := nearestWallDistance(lvl.Walls, next); dist < PlayerRadius-eps {
+						t.Fatalf("speed %v angle %v frame %v: %v is %v from a wall, want at least %v", speed, angle, frame, next, dist, PlayerRadius)
+					}
+					pos = next
+				}
+			}
+		}
+	}
+}
+
+func TestMoveAndSlideAlongWall(t *testing.T) {
+	wall := line32{X1: 0, Y1: 0, X2: 10, Y2: 0}
+	root := BuildBSPTree([]line32{wall})
+
+	// Walk diagonally into the wall, we should keep the sideways part of the move
+	pos := pos32{X: 2, Y: PlayerRadius + 0.01}
+	got := MoveAndSlide(root, nil, pos, pos32{X: 1, Y: -1}, PlayerRadius)
+
+	if math32.Abs(got.X-3) > 0.01 {
+		t.Errorf("slid to x %v, want 3", got.X)
+	}
+	if got.Y < PlayerRadius-0.001 {
+		t.Errorf("ended at y %v, inside the wall", got.Y)
+	}
+}
+
+func TestMoveAndSlideCorner(t *testing.T) {
+	// Two walls meeting in a concave corner at the origin
+	root := BuildBSPTree([]line32{
+		{X1: 0, Y1: 0, X2: 10, Y2: 0},
+		{X1: 0, Y1: 10, X2: 0, Y2: 0},
+	})
+
+	pos := pos32{X: 2, Y: 2}
+	for i := 0; i < 20; i++ {
+		pos = MoveAndSlide(root, nil, pos, pos32{X: -0.5, Y: -0.5}, PlayerRadius)
+	}
+
+	want := pos32{X: PlayerRadius, Y: PlayerRadius}
+	if math32.Abs(pos.X-want.X) > 0.01 || math32.Abs(pos.Y-want.Y) > 0.01 {
+		t.Errorf("stopped at %v, want wedged in the corner at %v", pos, want)
+	}
+}
+
+func TestMoveAndSlideThinWall(t *testing.T) {
+	// A single segment, far thinner than one frame of movement
+	wall := line32{X1: 5, Y1: -10, X2: 5, Y2: 10}
+	root := BuildBSPTree([]line32{wall})
+
+	for _, start := range []pos32{{X: 0, Y: 0}, {X: 10, Y: 0}} {
+		move := pos32{X: 10 - 2*start.X, Y: 0}
+		got := MoveAndSlide(root, nil, start, move, PlayerRadius)
+		if (got.X < 5) != (start.X < 5) {
+			t.Errorf("from %v tunnelled through the wall to %v", start, got)
+		}
+		if dist := math32.Abs(got.X - 5); math32.Abs(dist-PlayerRadius) > 0.01 {
+			t.Errorf("from %v stopped %v from the wall, want %v", start, dist, PlayerRadius)
+		}
+	}
+}
+
+func TestMoveAndSlideMaskedWall(t *testing.T) {
+	tests := []struct {
+		name        string
+		attrs       level.WallAttrs
+		wantThrough bool
+	}{
+		{"solid", level.WallAttrs{}, false},
+		{"masked", level.WallAttrs{Masked: true}, false},
+		{"masked passable", level.WallAttrs{Masked: true, Passable: true}, true},
+		{"two-sided", level.WallAttrs{TwoSided: true}, true},
+	}
+
+	for _, tt := range tests {
+		root := BuildBSPTree([]line32{{X1: 5, Y1: -10, X2: 5, Y2: 10, Attrs: tt.attrs}})
+
+		pos := pos32{X: 0, Y: 0}
+		for i := 0; i < 20; i++ {
+			pos = MoveAndSlide(root, nil, pos, pos32{X: 0.5, Y: 0}, PlayerRadius)
+		}
+		if through := pos.X > 5; through != tt.wantThrough {
+			t.Errorf("%v: ended at %v, through = %v, want %v", tt.name, pos, through, tt.wantThrough)
+		}
+	}
+}
+
+func TestMoveAndSlideWallEnd(t *testing.T) {
+	// Moving past the end of a wall should round it, not stop dead
+	root := BuildBSPTree([]line32{{X1: 0, Y1: 0, X2: 0, Y2: 5}})
+
+	pos := pos32{X: -2, Y: 5.1}
+	for i := 0; i < 40; i++ {
+		pos = MoveAndSlide(root, nil, pos, pos32{X: 0.1, Y: 0}, PlayerRadius)
+	}
+	if pos.X < 1 {
+		t.Errorf("stuck at %v on the end of the wall", pos)
+	}
+}
@@ -0,0 +1,338 @@
+package raycast
+
+import (
+	"cmp"
+	"level"
+	"slices"
+
+	"github.com/chewxy/math32"
+)
+
+// What one screen column shows, cast by CastColumn
+type RenderData struct {
+	X      int
+	RayDir pos32       // One unit long along the view, so distances along it are view depth
+	Slices []WallSlice // Solid wall parts, nearest first
+	Layers []WallSlice // See-through parts of masked walls and sprites, farthest first to blend in order
+	flats  []flatSpan  // Floor and ceiling rows not at the default heights
+	open   []openRows  // Rows left open past each wall hit, nearest first, for depth testing sprites
+	doors  []doorHit   // Doors the ray passes through, nearest first
+	Hits   []*BSPNode  // Walls and doors drawn in the column, for the automap
+}
+
+// Where a ray meets a door, which isn't in the tree so is merged into the walk by depth
+type doorHit struct {
+	node   *BSPNode
+	depth  float32
+	hitPos pos32
+}
+
+// Rows that farther geometry can still show through, past a wall at a view depth
+type openRows struct {
+	depth       float32
+	top, bottom int
+}
+
+// One part of a wall in a column: a whole wall, the upper or lower part around an opening,
+// or the see-through middle of a masked wall. Sprite columns are drawn as slices too.
+type WallSlice struct {
+	Top, Bottom         float32 // Screen rows of the whole part, before clipping
+	ClipTop, ClipBottom int     // Rows actually drawn
+	U, V0, V1           float32 // Texture u, and v at top and bottom in texture heights, repeating past 1
+	Depth               float32 // View depth, to order the layers
+	ValFloat            float32
+	Tex                 *Texture
+}
+
+// Rows of floor or ceiling at a height the row renderer doesn't draw
+type flatSpan struct {
+	y0, y1 int
+	z      float32 // Height of the floor or ceiling
+	tex    *Texture
+}
+
+// Cast the ray for one column through every wall and door it can see, nearest first
+func (f *Frame) CastColumn(col int, data *RenderData) {
+	f.WalkColumn(col, data, nil)
+}
+
+// Cast a column, noting every partition the walk passes in trace if it isn't nil
+func (f *Frame) WalkColumn(col int, data *RenderData, trace *[]TraceStep) {
+	data.X = col
+	data.RayDir = f.Cam.columnRay(col, f.dir, f.plane)
+	data.Slices = data.Slices[:0]
+	data.Layers = data.Layers[:0]
+	data.flats = data.flats[:0]
+	data.open = data.open[:0]
+	data.Hits = data.Hits[:0]
+	f.findDoorHits(data)
+
+	walk := columnWalk{frame: f, data: data, rayLength: f.Cam.columns[col].rayLength, bottom: f.Height, trace: trace}
+	walk.visit(f.Root)
+	walk.hitDoors(math32.MaxFloat32)
+
+	f.addSpriteLayers(data)
+	slices.SortFunc(data.Layers, func(a, b WallSlice) int { return cmp.Compare(b.Depth, a.Depth) })
+}
+
+// The state of a ray passing through the BSP tree
+type columnWalk struct {
+	frame     *Frame
+	data      *RenderData
+	rayLength float32
+
+	// Rows still open, farther geometry only shows between them
+	top, bottom int
+	done        bool
+
+	trace *[]TraceStep // For the BSP overlay, nil when rendering
+}
+
+// How a ray's walk through the tree dealt with a partition
+type TraceKind int
+
+const (
+	TraceMissed TraceKind = iota // Tested against the wall and missed it
+	TraceHit                     // Hit the wall
+	TraceCulled                  // Heading away from the partition line, so the far side was skipped
+)
+
+// One partition a ray's walk passed, in the order it was dealt with
+type TraceStep struct {
+	Node *BSPNode
+	Kind TraceKind
+}
+
+// Note a partition the walk passed, if it's being traced
+func (w *columnWalk) note(node *BSPNode, kind TraceKind) {
+	if w.trace != nil {
+		*w.trace = append(*w.trace, TraceStep{Node: node, Kind: kind})
+	}
+}
+
+// Visit the walls in a subtree front to back, stopping once the column is filled
+func (w *columnWalk) visit(node *BSPNode) {
+	if node == nil || w.done {
+		return
+	}
+	origin, rayDir := w.frame.Pos, w.data.RayDir
+
+	originSide := SideDistance(origin, node.Wall)
+	nearNode, farNode := node.Front, node.Back
+	if originSide < 0 {
+		nearNode, farNode = node.Back, node.Front
+	}
+	w.visit(nearNode)
+	if w.done {
+		return
+	}
+
+	// Heading away from the partition line, the far side can't be hit
+	approach := originSide - SideDistance(AddXY(origin, rayDir), node.Wall)
+	if originSide < 0 {
+		approach = -approach
+	}
+	if approach <= 0 && math32.Abs(originSide) >= splitEpsilon {
+		w.note(node, TraceCulled)
+		return
+	}
+
+	if depth, hitPos, hit := RayIntersectsSegment(origin, rayDir, node.Wall); hit {
+		if w.hitDoors(depth); w.done {
+			return
+		}
+		w.note(node, TraceHit)
+		w.hitWall(node, depth, hitPos)
+	} else {
+		w.note(node, TraceMissed)
+	}
+	w.visit(farNode)
+}
+
+// Find the doors a column's ray passes through, nearest first
+func (f *Frame) findDoorHits(data *RenderData) {
+	data.doors = data.doors[:0]
+	for _, d := range f.Doors {
+		if !d.Solid() {
+			continue
+		}
+		if depth, hitPos, hit := RayIntersectsSegment(f.Pos, data.RayDir, d.Node.Wall); hit {
+			data.doors = append(data.doors, doorHit{node: &d.Node, depth: depth, hitPos: hitPos})
+		}
+	}
+	slices.SortFunc(data.doors, func(a, b doorHit) int { return cmp.Compare(a.depth, b.depth) })
+}
+
+// Draw the doors nearer than a depth that haven't been drawn yet
+func (w *columnWalk) hitDoors(depth float32) {
+	for len(w.data.doors) > 0 && w.data.doors[0].depth < depth && !w.done {
+		door := w.data.doors[0]
+		w.data.doors = w.data.doors[1:]
+		w.hitWall(door.node, door.depth, door.hitPos)
+	}
+}
+
+// Screen row of a height at a view depth
+func (w *columnWalk) row(z, scale float32) int {
+	y := float32(w.frame.Height)/2 - (z-w.frame.EyeZ)*scale
+	return int(math32.Ceil(y - 0.5))
+}
+
+// Draw a wall the ray hit, and narrow the open rows to whatever can be seen past it
+func (w *columnWalk) hitWall(node *BSPNode, depth float32, hitPos pos32) {
+	wall := node.Wall
+	near, far := node.sectorsFrom(w.frame.Pos)
+	scale := float32(w.frame.Height) / depth
+	w.data.Hits = append(w.data.Hits, node)
+
+	// The near sector's ceiling and floor fill the rows between the last wall and this one
+	w.addFlat(w.top, min(w.row(near.Ceiling, scale), w.bottom), near.Ceiling, level.DefaultCeiling, w.frame.Ceiling)
+	w.addFlat(max(w.row(near.Floor, scale), w.top), w.bottom, near.Floor, level.DefaultFloor, w.frame.Floor)
+
+	// How much of the wall is open to see through, masked walls are seen through their texture
+	openTop, openBottom := near.Floor, near.Floor
+	if wall.Attrs.TwoSided || wall.Attrs.Masked {
+		openTop = min(near.Ceiling, far.Ceiling)
+		openBottom = max(near.Floor, far.Floor)
+	}
+	if wall.Attrs.Height != 0 {
+		// A low wall, seen over
+		openTop = near.Ceiling
+		openBottom = max(openBottom, near.Floor+wall.Attrs.Height)
+	}
+
+	part := WallSlice{
+		U:        wallTextureU(wall, hitPos),
+		Depth:    depth,
+		ValFloat: applyFalloff(depth*w.rayLength, lightIntensity, surfaceValue),
+		Tex:      w.frame.Textures.Get(wall.Attrs.Texture),
+	}
+
+	if openTop <= openBottom {
+		w.addSlice(&w.data.Slices, part, near.Ceiling, near.Floor, near.Ceiling, scale)
+		w.close(depth)
+		return
+	}
+
+	// Upper and lower parts, with the texture running on from the ceiling
+	if openTop < near.Ceiling {
+		w.addSlice(&w.data.Slices, part, near.Ceiling, openTop, near.Ceiling, scale)
+	}
+	if openBottom > near.Floor {
+		w.addSlice(&w.data.Slices, part, openBottom, near.Floor, near.Ceiling, scale)
+	}
+
+	// The opening of a masked wall is blended over whatever is behind it
+	if wall.Attrs.Masked {
+		w.addSlice(&w.data.Layers, part, openTop, openBottom, near.Ceiling, scale)
+	}
+
+	w.top = max(w.top, w.row(openTop, scale))
+	w.bottom = min(w.bottom, w.row(openBottom, scale))
+	if w.top >= w.bottom {
+		w.close(depth)
+		return
+	}
+	w.data.open = append(w.data.open, openRows{depth: depth, top: w.top, bottom: w.bottom})
+}
+
+// Stop the walk at a wall nothing can be seen past
+func (w *columnWalk) close(depth float32) {
+	w.data.open = append(w.data.open, openRows{depth: depth})
+	w.done = true
+}
+
+// Rows something at a view depth can be seen in, past the walls in front of it
+func (data *RenderData) openRows(depth float32, height int) (top, bottom int) {
+	top, bottom = 0, height
+	for _, rows := range data.open {
+		if rows.depth >= depth {
+			break
+		}
+		top, bottom = rows.top, rows.bottom
+	}
+	return top, bottom
+}
+
+// View depth of the wall that fills the column, false if the ray sees past every wall it hits
+func (data *RenderData) BlockedAt() (float32, bool) {
+	if n := len(data.open); n > 0 && data.open[n-1].top >= data.open[n-1].bottom {
+		return data.open[n-1].depth, true
+	}
+	return 0, false
+}
+
+// Add the part of a wall from height zTop down to zBottom to a list, clipped to the open rows
+func (w *columnWalk) addSlice(list *[]WallSlice, part WallSlice, zTop, zBottom, textureTop, scale float32) {
+	horizon := float32(w.frame.Height) / 2
+	part.Top = horizon - (zTop-w.frame.EyeZ)*scale
+	part.Bottom = horizon - (zBottom-w.frame.EyeZ)*scale
+	part.ClipTop = max(w.row(zTop, scale), w.top)
+	part.ClipBottom = min(w.row(zBottom, scale), w.bottom)
+	if part.ClipTop >= part.ClipBottom {
+		return
+	}
+
+	part.V0 = (textureTop - zTop) / textureRepeatDistance
+	part.V1 = (textureTop - zBottom) / textureRepeatDistance
+	*list = append(*list, part)
+}
+
+// Add rows of floor or ceiling, unless the row renderer already drew them at this height
+func (w *columnWalk) addFlat(y0, y1 int, z, defaultZ float32, tex *Texture) {
+	if y0 >= y1 || z == defaultZ {
+		return
+	}
+	w.data.flats = append(w.data.flats, flatSpan{y0: y0, y1: y1, z: z, tex: tex})
+}
+
+// Texture v for a row of a wall slice
+func (s *WallSlice) TextureV(y float32) float32 {
+	return s.V0 + (y-s.Top)/(s.Bottom-s.Top)*(s.V1-s.V0)
+}
+
+// Shade the floor and ceiling spans of a column into the frame
+func (f *Frame) ShadeFlats(data *RenderData) {
+	horizon := float32(f.Height) / 2
+	for _, span := range data.flats {
+		for y := span.y0; y < span.y1; y++ {
+			p := float32(y) - horizon
+			if p == 0 {
+				continue
+			}
+
+			// Distance to where the row meets the floor or ceiling
+			depth := (f.EyeZ - span.z) * float32(f.Height) / p
+			if depth <= 0 {
+				continue
+			}
+			spot := AddXY(f.Pos, ScaleXY(data.RayDir, depth))
+			shade := uint32(applyFalloff(depth, lightIntensity, surfaceValue) * 256)
+
+			span.tex.shadeTexel(f.Pixels[(y*f.Width+data.X)*4:], spot.X-math32.Floor(spot.X), spot.Y-math32.Floor(spot.Y), shade)
+		}
+	}
+}
+
+// Shade the wall slices of a column into the frame, then blend the layers over them
+func (f *Frame) ShadeWalls(data *RenderData) {
+	for i := range data.Slices {
+		slice := &data.Slices[i]
+		shade := uint32(slice.ValFloat * 256)
+
+		for y := slice.ClipTop; y < slice.ClipBottom; y++ {
+			v := slice.TextureV(float32(y) + 0.5)
+			slice.Tex.shadeTexel(f.Pixels[(y*f.Width+data.X)*4:], slice.U, v-math32.Floor(v), shade)
+		}
+	}
+
+	for i := range data.Layers {
+		slice := &data.Layers[i]
+		shade := uint32(slice.ValFloat * 256)
+
+		for y := slice.ClipTop; y < slice.ClipBottom; y++ {
+			v := slice.TextureV(float32(y) + 0.5)
+			slice.Tex.blendTexel(f.Pixels[(y*f.Width+data.X)*4:], slice.U, v-math32.Floor(v), shade)
+		}
+	}
+}
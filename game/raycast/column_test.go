@@ -0,0 +1,28 @@
+package raycast
+
+import "testing"
+
+func TestWalkColumnTrace(t *testing.T) {
+	frame, _ := spriteTestFrame(t, "testdata/sprites.txt")
+
+	var data RenderData
+	var trace []TraceStep
+	frame.WalkColumn(frame.Width/2, &data, &trace)
+
+	hits := 0
+	for _, step := range trace {
+		if step.Kind == TraceHit {
+			hits++
+		}
+	}
+	if hits == 0 || hits != len(data.Hits) {
+		t.Errorf("trace has %v hits, want the %v walls drawn", hits, len(data.Hits))
+	}
+
+	// Tracing doesn't change what's cast
+	var plain RenderData
+	frame.CastColumn(frame.Width/2, &plain)
+	if len(plain.Slices) != len(data.Slices) || plain.Slices[0] != data.Slices[0] {
+		t.Error("tracing the walk changed the column")
+	}
+}
@@ -0,0 +1,100 @@
+package raycast
+
+import (
+	"image"
+	"level"
+
+	"github.com/chewxy/math32"
+)
+
+// A frame rendered on the CPU into a premultiplied RGBA buffer
+type Frame struct {
+	Pixels         []byte
+	Width, Height  int
+	Pos            pos32
+	Angle          float32
+	EyeZ           float32 // Eye height, from the floor of the sector the camera is in
+	Cam            *Camera
+	Root           *BSPNode
+	Doors          []*Door
+	Textures       Textures
+	Floor, Ceiling *Texture
+
+	dir, plane pos32        // View direction and camera plane, set by Aim
+	Sprites    []SpriteView // Sprites in view, farthest first, set by ProjectSprites
+}
+
+// Work out the view direction and camera plane from the angle
+func (f *Frame) Aim() {
+	f.dir, f.plane = f.Cam.basis(f.Angle)
+}
+
+// Render a frame of a level with a texture set, without a window or GPU, with its doors closed.
+// The level and position are in world units, and the FOV is horizontal.
+func RenderFrame(lvl level.Level, textures Textures, pos pos32, angle float32, width, height int, fovDeg float32) *image.RGBA {
+	frame := &Frame{
+		Pixels: make([]byte, width*height*4), Width: width, Height: height,
+		Pos: pos, Angle: angle, EyeZ: EyeZAt(lvl.Sectors, pos), Cam: NewCamera(fovDeg, width),
+		Root: BuildBSPTree(lvl.Walls), Doors: NewDoors(lvl.Doors, lvl.Sectors), Textures: textures,
+	}
+	frame.Floor, frame.Ceiling = textures.Surfaces(lvl)
+	AssignSectors(frame.Root, lvl.Sectors)
+	frame.Aim()
+	frame.Sprites = frame.ProjectSprites(lvl.Sprites, lvl.Sectors, nil)
+
+	for y := height / 2; y < height; y++ {
+		frame.RenderFloorRow(y)
+	}
+
+	var data RenderData
+	for col := 0; col < width; col++ {
+		frame.CastColumn(col, &data)
+		frame.ShadeFlats(&data)
+		frame.ShadeWalls(&data)
+	}
+
+	return &image.RGBA{Pix: frame.Pixels, Stride: width * 4, Rect: image.Rect(0, 0, width, height)}
+}
+
+// Render one floor row and the ceiling row mirroring it, at the default heights.
+// Floors and ceilings at other heights are drawn over them by ShadeFlats.
+func (f *Frame) RenderFloorRow(y int) {
+	floorRow := f.Pixels[y*f.Width*4 : (y+1)*f.Width*4]
+	ceilingY := f.Height - y - 1
+	ceilingRow := f.Pixels[ceilingY*f.Width*4 : (ceilingY+1)*f.Width*4]
+
+	p := y - f.Height/2
+	if p == 0 {
+		// The horizon, nothing to see
+		clear(floorRow)
+		clear(ceilingRow)
+		return
+	}
+
+	// Distance along the view to where the rows meet the floor and ceiling
+	floorDistance := (f.EyeZ - level.DefaultFloor) * float32(f.Height) / float32(p)
+	ceilingDistance := (level.DefaultCeiling - f.EyeZ) * float32(f.Height) / float32(p)
+
+	// Shade the whole row by its distance, as walls are shaded by theirs
+	floorShade := uint32(applyFalloff(floorDistance, lightIntensity, surfaceValue) * 256)
+	ceilingShade := uint32(applyFalloff(ceilingDistance, lightIntensity, surfaceValue) * 256)
+
+	// Leftmost ray direction, and how far it moves per screen pixel
+	rayDir0 := SubXY(f.dir, f.plane)
+	step := ScaleXY(f.plane, 2/float32(f.Width))
+
+	floorPos := AddXY(f.Pos, ScaleXY(rayDir0, floorDistance))
+	floorStep := ScaleXY(step, floorDistance)
+	ceilingPos := AddXY(f.Pos, ScaleXY(rayDir0, ceilingDistance))
+	ceilingStep := ScaleXY(step, ceilingDistance)
+
+	for x := 0; x < f.Width; x++ {
+		// Position within the floor cell
+		f.Floor.shadeTexel(floorRow[x*4:x*4+4], floorPos.X-math32.Floor(floorPos.X), floorPos.Y-math32.Floor(floorPos.Y), floorShade)
+		f.Ceiling.shadeTexel(ceilingRow[x*4:x*4+4], ceilingPos.X-math32.Floor(ceilingPos.X), ceilingPos.Y-math32.Floor(ceilingPos.Y), ceilingShade)
+
+		// Move to the next position
+		floorPos = AddXY(floorPos, floorStep)
+		ceilingPos = AddXY(ceilingPos, ceilingStep)
+	}
+}
@@ -0,0 +1,95 @@
+package raycast
+
+import (
+	"level"
+
+	"github.com/chewxy/math32"
+)
+
+type doorDef = level.Door
+
+// A door in play. It isn't in the BSP tree, its wall is a leaf node of its own
+// that moves as it opens, so rays and collision check it alongside the tree.
+type Door struct {
+	Def     doorDef
+	Node    BSPNode // Where the door is now, with the sectors either side of its closed position
+	Open    float32 // 0 closed to 1 fully open
+	Opening bool
+	Wait    float32 // Seconds left before an open door starts closing
+}
+
+// Set up the doors of a level, closed
+func NewDoors(defs []doorDef, sectors []sector) []*Door {
+	list := make([]*Door, len(defs))
+	for i, def := range defs {
+		d := &Door{Def: def, Node: BSPNode{Wall: def.Wall, Source: def.Wall, isLeaf: true}}
+		AssignSectors(&d.Node, sectors)
+		list[i] = d
+	}
+	return list
+}
+
+// Move the door's wall to where it is at its current openness
+func (d *Door) Place() {
+	wall := d.Def.Wall
+	dir := movementDirection(wall)
+
+	switch d.Def.Motion {
+	case level.Slide:
+		// The near end slides towards the far one, and the texture, measured from it, goes with it
+		wall.X1 += dir.X * d.Open
+		wall.Y1 += dir.Y * d.Open
+
+	case level.Swing:
+		angle := d.Def.Angle
+		if angle == 0 {
+			angle = level.DefaultDoorAngle
+		}
+		sin, cos := math32.Sincos(angle * math32.Pi / 180 * d.Open)
+		wall.X2 = wall.X1 + dir.X*cos - dir.Y*sin
+		wall.Y2 = wall.Y1 + dir.X*sin + dir.Y*cos
+	}
+	d.Node.Wall = wall
+}
+
+// Whether the door is in the way at all, a sliding door is gone once fully open
+func (d *Door) Solid() bool {
+	return d.Def.Motion != level.Slide || d.Open < 1
+}
+
+// Start the door opening, to wait open before it closes again
+func (d *Door) Start() {
+	d.Opening = true
+	d.Wait = d.Def.Wait
+	if d.Wait == 0 {
+		d.Wait = level.DefaultDoorWait
+	}
+}
+
+// Move the door on by dt seconds, never closing it on a circle at pos
+func (d *Door) Update(dt float32, pos pos32, radius float32) {
+	speed := d.Def.Speed
+	if speed == 0 {
+		speed = level.DefaultDoorSpeed
+	}
+
+	if d.Opening {
+		d.Open = min(d.Open+speed*dt, 1)
+		if d.Open == 1 {
+			d.Wait -= dt
+			d.Opening = d.Wait > 0
+		}
+	} else if d.Open > 0 {
+		last := d.Open
+		d.Open = max(d.Open-speed*dt, 0)
+		d.Place()
+
+		var hit contact
+		if findContact(&d.Node, pos, pos32{}, radius, &hit); hit.found {
+			// Blocked, back open again
+			d.Open = last
+			d.Start()
+		}
+	}
+	d.Place()
+}
@@ -0,0 +1,67 @@
+package raycast
+
+import (
+	"level"
+	"testing"
+
+	"github.com/chewxy/math32"
+)
+
+// The rooms of testdata/doors.txt, with the level's doors closed
+func doorTestLevel(t *testing.T) (level.Level, *BSPNode, []*Door) {
+	lvl, err := LoadLevel("testdata/doors.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := BuildBSPTree(lvl.Walls)
+	AssignSectors(root, lvl.Sectors)
+	return lvl, root, NewDoors(lvl.Doors, lvl.Sectors)
+}
+
+func TestDoorPlace(t *testing.T) {
+	wall := line32{X1: 0, Y1: 0, X2: 2, Y2: 0}
+	const eps = 1e-5
+
+	slide := &Door{Def: doorDef{Wall: wall}, Open: 0.25}
+	slide.Place()
+	if got := slide.Node.Wall; got.X1 != 0.5 || got.X2 != 2 {
+		t.Errorf("sliding door a quarter open spans %v to %v, want 0.5 to 2", got.X1, got.X2)
+	}
+
+	swing := &Door{Def: doorDef{Wall: wall, Motion: level.Swing}, Open: 1}
+	swing.Place()
+	if got := swing.Node.Wall; got.X1 != 0 || math32.Abs(got.X2) > eps || math32.Abs(got.Y2-2) > eps {
+		t.Errorf("swinging door fully open ends at %v,%v, want 0,2", got.X2, got.Y2)
+	}
+
+	swing.Def.Angle = -45
+	swing.Place()
+	if got := swing.Node.Wall; math32.Abs(got.X2-math32.Sqrt2) > eps || math32.Abs(got.Y2+math32.Sqrt2) > eps {
+		t.Errorf("swinging door turned -45 degrees ends at %v,%v", got.X2, got.Y2)
+	}
+}
+
+func TestColumnHitsDoor(t *testing.T) {
+	lvl, root, list := doorTestLevel(t)
+
+	frame := &Frame{
+		Pixels: make([]byte, goldenWidth*goldenHeight*4), Width: goldenWidth, Height: goldenHeight,
+		Pos: lvl.Start, Angle: -math32.Pi / 2, EyeZ: EyeZAt(lvl.Sectors, lvl.Start), Cam: NewCamera(90, goldenWidth),
+		Root: root, Doors: list, Textures: LoadTextures(textureDir),
+	}
+	frame.Aim()
+
+	// Straight ahead through the doorway, the closed door fills the column
+	var data RenderData
+	frame.CastColumn(goldenWidth/2, &data)
+	if len(data.Slices) != 1 || data.Slices[0].Tex != frame.Textures.Get("door") || math32.Abs(data.Slices[0].Depth-5) > 0.001 {
+		t.Fatalf("got slices %v, want just the door 5 away", data.Slices)
+	}
+
+	// Open, the far wall shows through the doorway
+	list[0].Open = 1
+	frame.CastColumn(goldenWidth/2, &data)
+	if len(data.Slices) != 1 || math32.Abs(data.Slices[0].Depth-15) > 0.001 {
+		t.Errorf("got slices %v, want the far wall 15 away", data.Slices)
+	}
+}
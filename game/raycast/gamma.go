@@ -1,4 +1,4 @@
-package main
+package raycast
 
 import (
 	"image/color"
@@ -6,6 +6,14 @@ import (
 	"github.com/chewxy/math32"
 )
 
+// Lighting for walls, floors, ceilings and sprites
+const lightIntensity = 1000
+
+var (
+	wallColor    color.NRGBA = HSVtoRGB(180, 0.0, 0.8)
+	surfaceValue             = float32(wallColor.R+wallColor.G+wallColor.B) / 765.0 / 3.0
+)
+
 // Linearize sRGB to linear space (remove gamma correction)
 func sRGBToLinear(value float32) float32 {
 	if value <= 0.04045 {
@@ -73,5 +81,5 @@ func HSVtoRGB(h, s, v float32) color.NRGBA {
 	g := (g1 + m) * 255
 	b := (b1 + m) * 255
 
-	return color.NRGBA{R: uint8(r), G: uint8(g), B: uint8(b), A: 1}
+	return color.NRGBA{R: uint8(r), G: uint8(g), B: uint8(b), A: 255}
 }
@@ -0,0 +1,178 @@
+package raycast
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chewxy/math32"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden images in testdata/golden")
+
+const (
+	goldenDir = "testdata/golden"
+
+	goldenWidth  = 320
+	goldenHeight = 180
+
+	// A pixel matches if every channel is within this much of the golden image
+	goldenTolerance = 3
+	// Fraction of pixels allowed to differ, for edges that land on the other side of a column
+	goldenMaxBadPixels = 0.001
+)
+
+var goldenViews = []struct {
+	name  string
+	level string
+	pos   pos32
+	angle float32
+	fov   float32
+}{
+	// Level start, walls, floor and ceiling textures
+	{"level1_start", levelPath, pos32{X: 27.4, Y: 37.1}, -math32.Pi / 2, 90},
+	// Looking down a corridor, shading falls off with distance
+	{"level1_corridor", levelPath, pos32{X: 25, Y: 33}, 0.3 + math32.Pi, 90},
+	// Narrow field of view
+	{"level1_fov60", levelPath, pos32{X: 27.4, Y: 37.1}, 2.2 + math32.Pi, 60},
+	// Wall along the view, texture mapping and falloff shading
+	{"level2_falloff", "../../level2.txt", pos32{X: 5, Y: 3}, 2 + math32.Pi, 90},
+	// Facing a flat wall, which must stay flat after fisheye correction
+	{"level2_fisheye", "../../level2.txt", pos32{X: 4, Y: 25}, math32.Pi, 90},
+	// Up against a wall, lineHeight is larger than the screen and gets clipped
+	{"level2_clipped", "../../level2.txt", pos32{X: 2, Y: 25}, math32.Pi, 90},
+	// Raised sector seen through two-sided walls, upper and lower parts with floor and ceiling between
+	{"sectors_block", "testdata/sectors.txt", pos32{X: 8.5, Y: 13.8}, -1.3, 90},
+	// Sprites sorted and clipped against the walls, with transparency and falloff
+	{"sprites_depth", "testdata/sprites.txt", pos32{X: 10, Y: 18}, -math32.Pi / 2, 90},
+	// Farther walls and sprites showing through a masked wall, with a sprite in front of it
+	{"masked_grate", "testdata/masked.txt", pos32{X: 9, Y: 17}, -math32.Pi/2 + 0.3, 90},
+	// Closed doors drawn in their doorways, which aren't part of the tree
+	{"doors_closed", "testdata/doors.txt", pos32{X: 10, Y: 17}, -math32.Pi / 2, 90},
+}
+
+func TestGoldenFrames(t *testing.T) {
+	textures := LoadTextures(textureDir)
+
+	for _, view := range goldenViews {
+		t.Run(view.name, func(t *testing.T) {
+			lvl, err := LoadLevel(view.level)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := RenderFrame(lvl, textures, view.pos, view.angle, goldenWidth, goldenHeight, view.fov)
+
+			goldenPath := filepath.Join(goldenDir, view.name+".png")
+			if *updateGolden {
+				if err := writeTestPNG(goldenPath, got); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := readPNG(goldenPath)
+			if err != nil {
+				t.Fatalf("%v, run with -update to create it", err)
+			}
+
+			diff, bad := diffImages(got, want)
+			if float64(bad) > goldenMaxBadPixels*goldenWidth*goldenHeight {
+				diffPath := filepath.Join(goldenDir, view.name+".diff.png")
+				if err := writeTestPNG(diffPath, diff); err != nil {
+					t.Error(err)
+				}
+				t.Errorf("%v pixels differ from %v, see %v", bad, goldenPath, diffPath)
+			}
+		})
+	}
+}
+
+// A flat wall facing the camera must be the same height in every column
+func TestFisheyeCorrection(t *testing.T) {
+	lvl, err := LoadLevel("../../level2.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := &Frame{
+		Width: goldenWidth, Height: goldenHeight,
+		Pos: pos32{X: 4, Y: 25}, Angle: math32.Pi, EyeZ: eyeHeight, Cam: NewCamera(90, goldenWidth),
+		Root: BuildBSPTree(lvl.Walls),
+	}
+	frame.Aim()
+
+	var data RenderData
+	frame.CastColumn(goldenWidth/2, &data)
+	if len(data.Slices) != 1 {
+		t.Fatalf("middle column has %v wall slices, want 1", len(data.Slices))
+	}
+	want := data.Slices[0]
+
+	for col := 0; col < goldenWidth; col++ {
+		frame.CastColumn(col, &data)
+		if len(data.Slices) != 1 {
+			t.Fatalf("column %v has %v wall slices, want 1", col, len(data.Slices))
+		}
+		got := data.Slices[0]
+		if math32.Abs(got.Top-want.Top) > 0.01 || math32.Abs(got.Bottom-want.Bottom) > 0.01 {
+			t.Errorf("column %v wall spans rows %v to %v, want %v to %v", col, got.Top, got.Bottom, want.Top, want.Bottom)
+		}
+	}
+}
+
+// Compare two images, returning an image marking differences in red and how many pixels differ
+func diffImages(got, want image.Image) (*image.RGBA, int) {
+	bounds := want.Bounds()
+	diff := image.NewRGBA(bounds)
+	if got.Bounds() != bounds {
+		return diff, bounds.Dx() * bounds.Dy()
+	}
+
+	bad := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			gotColor := color.RGBAModel.Convert(got.At(x, y)).(color.RGBA)
+			wantColor := color.RGBAModel.Convert(want.At(x, y)).(color.RGBA)
+
+			if channelDiff(gotColor.R, wantColor.R) > goldenTolerance ||
+				channelDiff(gotColor.G, wantColor.G) > goldenTolerance ||
+				channelDiff(gotColor.B, wantColor.B) > goldenTolerance ||
+				channelDiff(gotColor.A, wantColor.A) > goldenTolerance {
+				diff.Set(x, y, color.RGBA{R: 255, A: 255})
+				bad++
+				continue
+			}
+
+			// Faded copy of the expected image, so the differences stand out
+			gray := uint8((uint32(wantColor.R) + uint32(wantColor.G) + uint32(wantColor.B)) / 3 / 4)
+			diff.Set(x, y, color.RGBA{R: gray, G: gray, B: gray, A: 255})
+		}
+	}
+	return diff, bad
+}
+
+func channelDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+func writeTestPNG(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return fmt.Errorf("unable to write %v: %w", path, err)
+	}
+	return file.Close()
+}
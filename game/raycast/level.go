@@ -0,0 +1,25 @@
+package raycast
+
+import (
+	"fmt"
+	"level"
+	"log"
+)
+
+// Read a level file, fill in the sectors its walls enclose and scale it into world units
+func LoadLevel(path string) (level.Level, error) {
+	lvl, err := level.Read(path)
+	if err != nil {
+		return lvl, err
+	}
+	if len(lvl.Walls) == 0 {
+		return lvl, fmt.Errorf("%v has no walls", path)
+	}
+
+	// Detect in file units, where the walls the editor snapped together meet exactly
+	lvl, issues := lvl.DetectSectors()
+	for _, issue := range issues {
+		log.Printf("%v: %v", path, issue)
+	}
+	return lvl.Scaled(level.ScaleDiv), nil
+}
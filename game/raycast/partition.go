@@ -0,0 +1,101 @@
+package raycast
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Tuning for picking BSP partition walls
+type bspHeuristic struct {
+	splitWeight float32 // Cost of one split, in walls of front/back imbalance
+	sampleSize  int     // Candidate walls scored per node, 0 scores them all
+	seed        int64   // Seed for picking the sampled candidates
+}
+
+// Summary of a built BSP tree
+type BSPStats struct {
+	Nodes, Depth, Splits int
+}
+
+var bspConfig = bspHeuristic{splitWeight: 8, sampleSize: 64, seed: 1}
+
+func (s BSPStats) String() string {
+	return fmt.Sprintf("nodes: %v, depth: %v, splits: %v", s.Nodes, s.Depth, s.Splits)
+}
+
+// Pick the wall to partition on, returning its index
+func choosePartition(walls []line32, heuristic bspHeuristic, rng *rand.Rand) int {
+	// On large maps only score a deterministic random sample of the walls
+	var candidates []int
+	if heuristic.sampleSize > 0 && heuristic.sampleSize < len(walls) {
+		candidates = rng.Perm(len(walls))[:heuristic.sampleSize]
+	} else {
+		candidates = make([]int, len(walls))
+		for i := range candidates {
+			candidates[i] = i
+		}
+	}
+
+	bestIndex := candidates[0]
+	var bestScore float32 = -1
+	for _, i := range candidates {
+		score := scorePartition(walls, i, heuristic)
+		if bestScore < 0 || score < bestScore {
+			bestScore = score
+			bestIndex = i
+		}
+	}
+	return bestIndex
+}
+
+// Score a partition wall, lower is better
+func scorePartition(walls []line32, partitionIndex int, heuristic bspHeuristic) float32 {
+	frontCount, backCount, splitCount := 0, 0, 0
+
+	for i, wall := range walls {
+		if i == partitionIndex {
+			continue
+		}
+		switch classifyWall(wall, walls[partitionIndex]) {
+		case sideFront:
+			frontCount++
+		case sideBack:
+			backCount++
+		default:
+			frontCount++
+			backCount++
+			splitCount++
+		}
+	}
+
+	balance := frontCount - backCount
+	if balance < 0 {
+		balance = -balance
+	}
+	return float32(balance) + heuristic.splitWeight*float32(splitCount)
+}
+
+// Stats for a built tree, splits are the pieces beyond the walls it was built from
+func TreeStats(root *BSPNode, walls []line32) BSPStats {
+	var stats BSPStats
+	var walk func(node *BSPNode, depth int)
+	walk = func(node *BSPNode, depth int) {
+		if node == nil {
+			return
+		}
+		stats.Nodes++
+		stats.Depth = max(stats.Depth, depth)
+		walk(node.Front, depth+1)
+		walk(node.Back, depth+1)
+	}
+	walk(root, 1)
+
+	usable := 0
+	for _, wall := range walls {
+		if wallLength(wall) >= splitEpsilon {
+			usable++
+		}
+	}
+	stats.Splits = stats.Nodes - usable
+	return stats
+}
@@ -0,0 +1,90 @@
+package raycast
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// Generate a room full of scattered boxes
+func generateTestLevel(seed int64, boxes int) []line32 {
+	rng := rand.New(rand.NewSource(seed))
+
+	level := BoxToVectors(0, 0, 100, 100)
+	for i := 0; i < boxes; i++ {
+		x, y := rng.Float32()*90, rng.Float32()*90
+		level = append(level, BoxToVectors(x, y, 1+rng.Float32()*9, 1+rng.Float32()*9)...)
+	}
+	return level
+}
+
+func TestBSPHeuristicReport(t *testing.T) {
+	level1 := loadTestLevel(t)
+	levels := []struct {
+		name  string
+		walls []line32
+	}{
+		{levelPath, level1.Walls},
+		{"generated 50", generateTestLevel(1, 50)},
+		{"generated 200", generateTestLevel(2, 200)},
+	}
+	heuristics := []struct {
+		name      string
+		heuristic bspHeuristic
+	}{
+		{"balance only", bspHeuristic{splitWeight: 0}},
+		{"default", bspConfig},
+		{"splits only", bspHeuristic{splitWeight: 1000}},
+		{"sample 8", bspHeuristic{splitWeight: 8, sampleSize: 8, seed: 1}},
+	}
+
+	for _, level := range levels {
+		for _, h := range heuristics {
+			root, stats := buildBSPTreeWith(level.walls, h.heuristic)
+			t.Logf("%-14v %-13v %v", level.name, h.name, stats)
+
+			if got := len(collectWalls(root, nil)); got != stats.Nodes {
+				t.Errorf("%v/%v: tree has %v nodes, stats report %v", level.name, h.name, got, stats.Nodes)
+			}
+
+			_, again := buildBSPTreeWith(level.walls, h.heuristic)
+			if again != stats {
+				t.Errorf("%v/%v: rebuilding gave %v, want %v", level.name, h.name, again, stats)
+			}
+		}
+	}
+}
+
+func TestChoosePartition(t *testing.T) {
+	// Four short parallel walls crossed by one long wall
+	level := []line32{
+		{X1: -1, Y1: 5, X2: 4, Y2: 5},
+		{X1: 0, Y1: 0, X2: 0, Y2: 1},
+		{X1: 1, Y1: 0, X2: 1, Y2: 1},
+		{X1: 2, Y1: 0, X2: 2, Y2: 1},
+		{X1: 3, Y1: 0, X2: 3, Y2: 1},
+	}
+
+	tests := []struct {
+		name      string
+		heuristic bspHeuristic
+		want      int
+	}{
+		{"balance only", bspHeuristic{splitWeight: 0}, 2},
+		{"avoid splits", bspHeuristic{splitWeight: 8}, 0},
+	}
+
+	for _, tt := range tests {
+		if got := choosePartition(level, tt.heuristic, rand.New(rand.NewSource(1))); got != tt.want {
+			t.Errorf("%v: choosePartition picked wall %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTreeStats(t *testing.T) {
+	lvl := loadTestLevel(t)
+	root, built := buildBSPTreeWith(lvl.Walls, bspConfig)
+
+	if got := TreeStats(root, lvl.Walls); got != built {
+		t.Errorf("stats from the tree %v, want %v from building it", got, built)
+	}
+}
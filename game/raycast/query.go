@@ -0,0 +1,135 @@
+package raycast
+
+import "github.com/chewxy/math32"
+
+// Spatial queries over the BSP tree, for gameplay, AI and collision to share.
+// Each one only visits the subspaces it can reach, so they stay cheap on big levels.
+
+// What a ray cast hit
+type RayHit struct {
+	Wall   line32
+	Node   *BSPNode
+	Pos    pos32
+	Normal pos32   // Unit vector out of the wall, back towards where the ray came from
+	Dist   float32 // Distance along the ray, in lengths of its direction
+	Found  bool
+}
+
+// The wall closest to a point, and how far away it is.
+// Walls on the far side of a partition are at least as far as the line, so those subspaces are skipped once something nearer is found.
+func NearestWall(root *BSPNode, p pos32) (line32, float32, bool) {
+	var best line32
+	bestDist := float32(math32.MaxFloat32)
+	found := false
+
+	var visit func(node *BSPNode)
+	visit = func(node *BSPNode) {
+		if node == nil {
+			return
+		}
+		side := SideDistance(p, node.Wall)
+		nearNode, farNode := node.Front, node.Back
+		if side < 0 {
+			nearNode, farNode = node.Back, node.Front
+		}
+
+		visit(nearNode)
+		if math32.Abs(side) >= bestDist {
+			return
+		}
+		if dist := distanceToWall(node.Wall, p); dist < bestDist {
+			best, bestDist, found = node.Wall, dist, true
+		}
+		visit(farNode)
+	}
+	visit(root)
+	return best, bestDist, found
+}
+
+// Every wall within a radius of a point, appended to buf
+func WallsWithin(root *BSPNode, p pos32, radius float32, buf []line32) []line32 {
+	if root == nil {
+		return buf
+	}
+	side := SideDistance(p, root.Wall)
+	if side > -radius {
+		buf = WallsWithin(root.Front, p, radius, buf)
+	}
+	if side < radius {
+		buf = WallsWithin(root.Back, p, radius, buf)
+	}
+	if math32.Abs(side) < radius && distanceToWall(root.Wall, p) < radius {
+		buf = append(buf, root.Wall)
+	}
+	return buf
+}
+
+// The first wall or door a ray hits within maxDist lengths of its direction.
+// Walls that pass returns true for are ignored, pass may be nil to stop at every wall.
+func CastRay(root *BSPNode, doors []*Door, origin, dir pos32, maxDist float32, pass func(*BSPNode) bool) RayHit {
+	hit := RayHit{Dist: maxDist}
+	castRayNode(root, origin, dir, pass, &hit)
+	for _, d := range doors {
+		if d.Solid() {
+			castRayNode(&d.Node, origin, dir, pass, &hit)
+		}
+	}
+
+	if hit.Found {
+		wallDir := NormalizeXY(movementDirection(hit.Wall))
+		hit.Normal = pos32{X: -wallDir.Y, Y: wallDir.X}
+		if DotXY(hit.Normal, dir) > 0 {
+			hit.Normal = ScaleXY(hit.Normal, -1)
+		}
+	}
+	return hit
+}
+
+// Find the nearest hit in a subtree front to back, skipping the far side once it can't be nearer
+func castRayNode(node *BSPNode, origin, dir pos32, pass func(*BSPNode) bool, hit *RayHit) {
+	if node == nil {
+		return
+	}
+
+	originSide := SideDistance(origin, node.Wall)
+	nearNode, farNode := node.Front, node.Back
+	if originSide < 0 {
+		nearNode, farNode = node.Back, node.Front
+	}
+	castRayNode(nearNode, origin, dir, pass, hit)
+
+	// Heading away from the partition line, or stopped before reaching it
+	approach := originSide - SideDistance(AddXY(origin, dir), node.Wall)
+	if originSide < 0 {
+		approach = -approach
+	}
+	if approach <= 0 && math32.Abs(originSide) >= splitEpsilon {
+		return
+	}
+	if approach > 0 && hit.Dist < math32.Abs(originSide)/approach {
+		return
+	}
+
+	if dist, pos, ok := RayIntersectsSegment(origin, dir, node.Wall); ok && dist < hit.Dist && (pass == nil || !pass(node)) {
+		*hit = RayHit{Wall: node.Wall, Node: node, Pos: pos, Dist: dist, Found: true}
+	}
+	castRayNode(farNode, origin, dir, pass, hit)
+}
+
+// Whether a wall can be seen through: an opening between sectors, a masked wall's texture, or over a low wall
+func (node *BSPNode) SeeThrough() bool {
+	attrs := node.Wall.Attrs
+	if attrs.Height != 0 {
+		return true
+	}
+	if !(attrs.TwoSided || attrs.Masked) {
+		return false
+	}
+	front, back := node.sides()
+	return min(front.Ceiling, back.Ceiling) > max(front.Floor, back.Floor)
+}
+
+// Whether nothing solid stands between two points
+func LineOfSight(root *BSPNode, doors []*Door, a, b pos32) bool {
+	return !CastRay(root, doors, a, SubXY(b, a), 1, (*BSPNode).SeeThrough).Found
+}
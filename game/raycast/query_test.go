@@ -0,0 +1,126 @@
+package raycast
+
+import (
+	"level"
+	"testing"
+
+	"github.com/chewxy/math32"
+)
+
+// Points spread over level 1, inside and outside its rooms
+func queryTestPoints() []pos32 {
+	var points []pos32
+	for x := float32(0); x < 60; x += 2.7 {
+		for y := float32(0); y < 60; y += 3.1 {
+			points = append(points, pos32{X: x, Y: y})
+		}
+	}
+	return points
+}
+
+func TestDistanceToWall(t *testing.T) {
+	wall := line32{X1: 0, Y1: 0, X2: 4, Y2: 0}
+	tests := []struct {
+		name string
+		pos  pos32
+		want float32
+	}{
+		{"alongside", pos32{X: 3, Y: 2}, 2},
+		{"behind", pos32{X: 1, Y: -0.5}, 0.5},
+		{"past the far end", pos32{X: 7, Y: 4}, 5},
+		{"before the near end", pos32{X: -1, Y: 0}, 1},
+		{"on it", pos32{X: 2, Y: 0}, 0},
+	}
+	for _, test := range tests {
+		if got := distanceToWall(wall, test.pos); math32.Abs(got-test.want) > 1e-5 {
+			t.Errorf("%v: distance %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNearestWallMatchesFull(t *testing.T) {
+	root := BuildBSPTree(loadTestLevel(t).Walls)
+	all := collectWalls(root, nil)
+
+	for _, p := range queryTestPoints() {
+		want := float32(math32.MaxFloat32)
+		for _, wall := range all {
+			want = min(want, distanceToWall(wall, p))
+		}
+		wall, got, ok := NearestWall(root, p)
+		if !ok || math32.Abs(got-want) > 0.001 || math32.Abs(distanceToWall(wall, p)-got) > 0.001 {
+			t.Errorf("at %v: nearest wall %v at %v, want %v away", p, wall, got, want)
+		}
+	}
+}
+
+func TestWallsWithinMatchesFull(t *testing.T) {
+	root := BuildBSPTree(loadTestLevel(t).Walls)
+	all := collectWalls(root, nil)
+
+	var buf []line32
+	for _, p := range queryTestPoints() {
+		for _, radius := range []float32{0.5, 2, 6} {
+			want := 0
+			for _, wall := range all {
+				if distanceToWall(wall, p) < radius {
+					want++
+				}
+			}
+			buf = WallsWithin(root, p, radius, buf[:0])
+			if len(buf) != want {
+				t.Errorf("at %v: %v walls within %v, want %v", p, len(buf), radius, want)
+			}
+			for _, wall := range buf {
+				if distanceToWall(wall, p) >= radius {
+					t.Errorf("at %v: wall %v is %v away, outside %v", p, wall, distanceToWall(wall, p), radius)
+				}
+			}
+		}
+	}
+}
+
+func TestCastRay(t *testing.T) {
+	root := BuildBSPTree([]line32{
+		{X1: 0, Y1: 0, X2: 10, Y2: 0},
+		{X1: 0, Y1: 5, X2: 10, Y2: 5, Attrs: level.WallAttrs{TwoSided: true}},
+	})
+
+	hit := CastRay(root, nil, pos32{X: 2, Y: 8}, pos32{X: 0, Y: -1}, 20, nil)
+	if !hit.Found || hit.Dist != 3 || hit.Pos != (pos32{X: 2, Y: 5}) || hit.Normal != (pos32{X: 0, Y: 1}) {
+		t.Errorf("hit %+v, want the near wall 3 away at 2,5 facing back up", hit)
+	}
+
+	// Passing the opening goes on to the wall behind it
+	hit = CastRay(root, nil, pos32{X: 2, Y: 8}, pos32{X: 0, Y: -1}, 20, (*BSPNode).SeeThrough)
+	if !hit.Found || hit.Dist != 8 || hit.Pos != (pos32{X: 2, Y: 0}) {
+		t.Errorf("hit %+v, want the far wall 8 away at 2,0", hit)
+	}
+
+	// Nothing within reach
+	if hit := CastRay(root, nil, pos32{X: 2, Y: 8}, pos32{X: 0, Y: -1}, 2, nil); hit.Found {
+		t.Errorf("hit %+v beyond the ray's reach", hit)
+	}
+}
+
+func TestLineOfSight(t *testing.T) {
+	lvl, root, list := doorTestLevel(t)
+	slide := list[0]
+	beyond := pos32{X: 6.5, Y: 5}
+
+	if !LineOfSight(root, list, lvl.Start, pos32{X: 10, Y: 15}) {
+		t.Error("can't see across the room the player starts in")
+	}
+	if LineOfSight(root, list, lvl.Start, beyond) {
+		t.Error("can see through the closed door")
+	}
+
+	slide.Open = 1
+	slide.Place()
+	if !LineOfSight(root, list, lvl.Start, beyond) {
+		t.Error("can't see through the open doorway")
+	}
+	if LineOfSight(root, list, lvl.Start, pos32{X: 2, Y: 5}) {
+		t.Error("can see through the wall beside the door")
+	}
+}
@@ -0,0 +1,10 @@
+// Package raycast is the raycaster without a window: the BSP tree, sectors, doors and
+// collision, and a CPU renderer that draws frames of a level with a set of textures.
+// It doesn't use ebiten, so frames can be rendered and tested on a machine without a display.
+package raycast
+
+import "level"
+
+type line32 = level.Line32
+
+type pos32 = level.Pos32
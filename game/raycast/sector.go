@@ -0,0 +1,91 @@
+package raycast
+
+import "level"
+
+type sector = level.Sector
+
+const (
+	eyeHeight     = 0.5  // Eye above the floor of the sector the player is in
+	maxStepHeight = 0.25 // Tallest step the player can walk up through a two-sided wall
+	playerHeight  = 0.75 // Headroom the player needs to fit through an opening
+)
+
+// Heights of the space not covered by any sector
+var defaultSector = &sector{Floor: level.DefaultFloor, Ceiling: level.DefaultCeiling}
+
+// Distance off a wall to probe for the sectors either side of it
+const sectorProbe = 0.01
+
+// Sector a point is in, the last listed if they overlap
+func sectorAt(sectors []sector, p pos32) *sector {
+	for i := len(sectors) - 1; i >= 0; i-- {
+		if sectors[i].Contains(p) {
+			return &sectors[i]
+		}
+	}
+	return defaultSector
+}
+
+// Eye height for a camera standing at p
+func EyeZAt(sectors []sector, p pos32) float32 {
+	return sectorAt(sectors, p).Floor + eyeHeight
+}
+
+// Work out which sector is on each side of every wall in the tree
+func AssignSectors(node *BSPNode, sectors []sector) {
+	if node == nil {
+		return
+	}
+
+	// Probe just off the middle of the wall, on its front side and then its back side
+	dir := NormalizeXY(movementDirection(node.Wall))
+	normal := pos32{X: -dir.Y, Y: dir.X}
+	mid := pos32{X: (node.Wall.X1 + node.Wall.X2) / 2, Y: (node.Wall.Y1 + node.Wall.Y2) / 2}
+	if SideDistance(AddXY(mid, normal), node.Wall) < 0 {
+		normal = ScaleXY(normal, -1)
+	}
+	node.frontSector = sectorAt(sectors, AddXY(mid, ScaleXY(normal, sectorProbe)))
+	node.backSector = sectorAt(sectors, SubXY(mid, ScaleXY(normal, sectorProbe)))
+
+	AssignSectors(node.Front, sectors)
+	AssignSectors(node.Back, sectors)
+}
+
+// Sectors on the front and back of a node's wall
+func (node *BSPNode) sides() (front, back *sector) {
+	front, back = node.frontSector, node.backSector
+	if front == nil {
+		front = defaultSector
+	}
+	if back == nil {
+		back = defaultSector
+	}
+	return front, back
+}
+
+// Sectors on the near and far side of a node's wall, seen from p
+func (node *BSPNode) sectorsFrom(p pos32) (near, far *sector) {
+	near, far = node.sides()
+	if SideDistance(p, node.Wall) < 0 {
+		near, far = far, near
+	}
+	return near, far
+}
+
+// Whether the player can walk through a wall.
+// Only two-sided walls, and masked walls flagged passable, with a low enough step and a tall enough opening let them by.
+func (node *BSPNode) passable() bool {
+	attrs := node.Wall.Attrs
+	if attrs.Masked && !attrs.Passable {
+		return false
+	}
+	if !(attrs.TwoSided || attrs.Masked) || attrs.Height != 0 {
+		return false
+	}
+
+	front, back := node.sides()
+	floor := max(front.Floor, back.Floor)
+	ceiling := min(front.Ceiling, back.Ceiling)
+	step := floor - min(front.Floor, back.Floor)
+	return step <= maxStepHeight && ceiling-floor >= playerHeight
+}
@@ -0,0 +1,103 @@
+package raycast
+
+import (
+	"level"
+	"testing"
+)
+
+func TestAssignSectors(t *testing.T) {
+	lvl, err := LoadLevel("testdata/sectors.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	room, block := &lvl.Sectors[0], &lvl.Sectors[1]
+
+	root := BuildBSPTree(lvl.Walls)
+	AssignSectors(root, lvl.Sectors)
+
+	for _, node := range collectNodes(root, nil) {
+		front, back := node.sides()
+		if node.Wall.Attrs.TwoSided {
+			// Each side of the block's walls is the room on one side and the block on the other
+			if !(front == room && back == block) && !(front == block && back == room) {
+				t.Errorf("two-sided wall %v has sectors %+v and %+v", node.Wall, *front, *back)
+			}
+			if node.passable() {
+				t.Errorf("wall %v is passable with a %v step", node.Wall, block.Floor-room.Floor)
+			}
+			continue
+		}
+		// The room's outer walls have the room in front and nothing behind
+		if (front == room) == (back == room) {
+			t.Errorf("outer wall %v has sectors %+v and %+v", node.Wall, *front, *back)
+		}
+	}
+}
+
+func TestLoadLevelDetectsSectors(t *testing.T) {
+	// Level 2 doesn't declare any sectors, its walls enclose them
+	lvl, err := LoadLevel("../../level2.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sectorAt(lvl.Sectors, pos32{X: 25, Y: 25}) == defaultSector {
+		t.Error("the middle of the room isn't in a sector")
+	}
+
+	// The declared room gets the block cut out of it
+	lvl, err = LoadLevel("testdata/sectors.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lvl.Sectors) != 2 || len(lvl.Sectors[0].Holes) != 1 {
+		t.Errorf("sectors = %+v, want the room with the block as a hole, then the block", lvl.Sectors)
+	}
+}
+
+func TestEyeZ(t *testing.T) {
+	lvl, err := LoadLevel("testdata/sectors.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if z := EyeZAt(lvl.Sectors, pos32{X: 2, Y: 2}); z != eyeHeight {
+		t.Errorf("eye height in the room = %v, want %v", z, eyeHeight)
+	}
+	if z := EyeZAt(lvl.Sectors, pos32{X: 10, Y: 10}); z != 0.3+eyeHeight {
+		t.Errorf("eye height on the block = %v, want %v", z, 0.3+eyeHeight)
+	}
+	if z := EyeZAt(nil, pos32{X: 10, Y: 10}); z != eyeHeight {
+		t.Errorf("eye height without sectors = %v, want %v", z, eyeHeight)
+	}
+}
+
+func TestPassableStep(t *testing.T) {
+	low := &sector{Floor: 0, Ceiling: 1}
+	tests := []struct {
+		name string
+		back *sector
+		want bool
+	}{
+		{"same heights", &sector{Floor: 0, Ceiling: 1}, true},
+		{"low step", &sector{Floor: maxStepHeight, Ceiling: 1.5}, true},
+		{"high step", &sector{Floor: maxStepHeight * 2, Ceiling: 1.5}, false},
+		{"low ceiling", &sector{Floor: 0, Ceiling: playerHeight / 2}, false},
+	}
+
+	for _, tt := range tests {
+		node := &BSPNode{Wall: line32{X2: 1, Attrs: level.WallAttrs{TwoSided: true}}, frontSector: low, backSector: tt.back}
+		if got := node.passable(); got != tt.want {
+			t.Errorf("%v: passable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Collect every node in a subtree
+func collectNodes(node *BSPNode, out []*BSPNode) []*BSPNode {
+	if node == nil {
+		return out
+	}
+	out = append(out, node)
+	out = collectNodes(node.Front, out)
+	return collectNodes(node.Back, out)
+}
@@ -0,0 +1,111 @@
+package raycast
+
+import (
+	"cmp"
+	"level"
+	"slices"
+
+	"github.com/chewxy/math32"
+)
+
+type sprite = level.Sprite
+
+// Closest a sprite can be and still be drawn, so it doesn't blow up over the whole screen
+const spriteNearClip = 0.05
+
+// Where a sprite lands on screen this frame, from ProjectSprites
+type SpriteView struct {
+	tex         *Texture
+	depth       float32 // View depth, comparable with the wall depths in a column
+	left, right float32 // Screen columns, before clipping
+	top, bottom float32 // Screen rows, before clipping
+	x0, x1      int     // Columns actually covered
+	valFloat    float32
+}
+
+// Work out where each sprite lands on screen, sorted farthest first so nearer ones draw over them.
+// The views are appended to buf, to reuse it between frames.
+func (f *Frame) ProjectSprites(sprites []sprite, sectors []sector, buf []SpriteView) []SpriteView {
+	views := buf[:0]
+	planeUnit := ScaleXY(f.plane, 1/f.Cam.planeLength)
+	columnsPerUnit := float32(f.Width) / 2 / f.Cam.planeLength
+
+	for i := range sprites {
+		spr := &sprites[i]
+		rel := SubXY(spr.Pos, f.Pos)
+		depth := DotXY(rel, f.dir)
+		if depth < spriteNearClip {
+			continue
+		}
+
+		tex := f.Textures.Get(spr.Texture)
+		size := spr.Size
+		if size == 0 {
+			size = level.DefaultSpriteSize
+		}
+
+		// Centre column, and half the width in columns, keeping the image's aspect
+		centre := (DotXY(rel, planeUnit)/depth + f.Cam.planeLength) * columnsPerUnit
+		halfWidth := size * float32(tex.Width) / float32(tex.Height) / 2 / depth * columnsPerUnit
+
+		// Sprites stand on the floor of the sector they're in
+		scale := float32(f.Height) / depth
+		floor := sectorAt(sectors, spr.Pos).Floor
+		horizon := float32(f.Height) / 2
+
+		view := SpriteView{
+			tex:    tex,
+			depth:  depth,
+			left:   centre - halfWidth,
+			right:  centre + halfWidth,
+			top:    horizon - (floor+size-f.EyeZ)*scale,
+			bottom: horizon - (floor-f.EyeZ)*scale,
+		}
+		view.x0 = max(int(math32.Ceil(view.left-0.5)), 0)
+		view.x1 = min(int(math32.Ceil(view.right-0.5)), f.Width)
+		if view.x0 >= view.x1 {
+			continue
+		}
+		view.valFloat = applyFalloff(math32.Sqrt(DotXY(rel, rel)), lightIntensity, surfaceValue)
+		views = append(views, view)
+	}
+
+	slices.SortFunc(views, func(a, b SpriteView) int { return cmp.Compare(b.depth, a.depth) })
+	return views
+}
+
+// Rows of a sprite that can be seen in a column, past the walls in front of it
+func (view *SpriteView) visibleRows(data *RenderData, height int) (y0, y1 int) {
+	top, bottom := data.openRows(view.depth, height)
+	y0 = max(int(math32.Ceil(view.top-0.5)), top)
+	y1 = min(int(math32.Ceil(view.bottom-0.5)), bottom)
+	return y0, y1
+}
+
+// Texture u for a column of a sprite
+func (view *SpriteView) textureU(x int) float32 {
+	return (float32(x) + 0.5 - view.left) / (view.right - view.left)
+}
+
+// Add the visible rows of the sprites covering a column to its layers
+func (f *Frame) addSpriteLayers(data *RenderData) {
+	for i := range f.Sprites {
+		view := &f.Sprites[i]
+		if data.X < view.x0 || data.X >= view.x1 {
+			continue
+		}
+
+		y0, y1 := view.visibleRows(data, f.Height)
+		if y0 >= y1 {
+			continue
+		}
+		data.Layers = append(data.Layers, WallSlice{
+			Top: view.top, Bottom: view.bottom,
+			ClipTop: y0, ClipBottom: y1,
+			U: view.textureU(data.X), V0: 0, V1: 1,
+			Depth:    view.depth,
+			ValFloat: view.valFloat,
+			Tex:      view.tex,
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package raycast
+
+import (
+	"testing"
+
+	"github.com/chewxy/math32"
+)
+
+// A frame looking down a level from its start, ready to cast
+func spriteTestFrame(t *testing.T, path string) (*Frame, []sprite) {
+	lvl, err := LoadLevel(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame := &Frame{
+		Pixels: make([]byte, goldenWidth*goldenHeight*4), Width: goldenWidth, Height: goldenHeight,
+		Pos: lvl.Start, Angle: -math32.Pi / 2, EyeZ: EyeZAt(lvl.Sectors, lvl.Start), Cam: NewCamera(90, goldenWidth),
+		Root: BuildBSPTree(lvl.Walls), Textures: LoadTextures(textureDir),
+	}
+	AssignSectors(frame.Root, lvl.Sectors)
+	frame.Aim()
+	frame.Sprites = frame.ProjectSprites(lvl.Sprites, lvl.Sectors, nil)
+	return frame, lvl.Sprites
+}
+
+func TestProjectSpritesFarthestFirst(t *testing.T) {
+	frame, sprites := spriteTestFrame(t, "testdata/sprites.txt")
+
+	if len(frame.Sprites) != len(sprites) {
+		t.Fatalf("%v sprites in view, want all %v", len(frame.Sprites), len(sprites))
+	}
+	for i := 1; i < len(frame.Sprites); i++ {
+		if frame.Sprites[i].depth > frame.Sprites[i-1].depth {
+			t.Errorf("sprite %v at depth %v is drawn after a nearer one at %v", i, frame.Sprites[i].depth, frame.Sprites[i-1].depth)
+		}
+	}
+
+	// Turned round, they're all behind the camera
+	frame.Angle += math32.Pi
+	frame.Aim()
+	if views := frame.ProjectSprites(sprites, nil, nil); len(views) != 0 {
+		t.Errorf("%v sprites behind the camera are in view", len(views))
+	}
+}
+
+func TestSpriteDepthClip(t *testing.T) {
+	frame, _ := spriteTestFrame(t, "testdata/sprites.txt")
+
+	// The barrel behind the block is farthest, and is only seen between the block's upper and lower parts
+	behind := &frame.Sprites[0]
+	col := (behind.x0 + behind.x1) / 2
+	var data RenderData
+	frame.CastColumn(col, &data)
+
+	y0, y1 := behind.visibleRows(&data, frame.Height)
+	if y0 >= y1 {
+		t.Fatal("the barrel behind the block can't be seen at all")
+	}
+	if float32(y0) <= behind.top-0.5 && float32(y1) >= behind.bottom-0.5 {
+		t.Errorf("rows %v-%v show the whole barrel (%v-%v), want it cut off by the block", y0, y1, behind.top, behind.bottom)
+	}
+
+	// Nothing is in front of the nearest one
+	near := &frame.Sprites[len(frame.Sprites)-1]
+	col = (near.x0 + near.x1) / 2
+	frame.CastColumn(col, &data)
+	if y0, y1 := near.visibleRows(&data, frame.Height); y0 != int(math32.Ceil(near.top-0.5)) || y1 != int(math32.Ceil(near.bottom-0.5)) {
+		t.Errorf("rows %v-%v of the nearest barrel are visible, want all of %v-%v", y0, y1, near.top, near.bottom)
+	}
+}
+
+func TestLayersFarthestFirst(t *testing.T) {
+	frame, _ := spriteTestFrame(t, "testdata/masked.txt")
+
+	// Looking at the barrel behind the grate, it has to be blended before the grate
+	behind := &frame.Sprites[0]
+	var data RenderData
+	frame.CastColumn((behind.x0+behind.x1)/2, &data)
+
+	if len(data.Layers) != 2 {
+		t.Fatalf("got %v layers, want the barrel and the grate", len(data.Layers))
+	}
+	if data.Layers[0].Tex != behind.tex || data.Layers[1].Tex != frame.Textures.Get("grate") {
+		t.Errorf("layers are %v then %v, want the barrel then the grate", data.Layers[0].Tex, data.Layers[1].Tex)
+	}
+
+	// The grate doesn't hide the wall behind it
+	if len(data.Slices) == 0 || data.Slices[len(data.Slices)-1].Depth <= data.Layers[1].Depth {
+		t.Error("the far wall isn't drawn behind the grate")
+	}
+}
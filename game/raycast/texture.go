@@ -0,0 +1,162 @@
+package raycast
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"level"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	defaultTextureName = "test"
+	floorTextureName   = "floor"
+	ceilingTextureName = "ceiling"
+
+	checkerSize   = 64
+	checkerSquare = 8
+)
+
+// A texture's pixels, premultiplied RGBA
+type Texture struct {
+	Pixels        []byte
+	Width, Height int
+}
+
+// Textures keyed by file name without the extension
+type Textures map[string]*Texture
+
+// Shown for textures that aren't in a set
+var missingTexture = newTexture(checkerboard())
+
+// Load every PNG in a directory into a texture set
+func LoadTextures(dir string) Textures {
+	set := Textures{}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("Unable to read texture directory: %v", err)
+		return set
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !strings.EqualFold(filepath.Ext(file.Name()), ".png") {
+			continue
+		}
+
+		img, err := readPNG(filepath.Join(dir, file.Name()))
+		if err != nil {
+			log.Printf("Unable to load texture %v: %v", file.Name(), err)
+			continue
+		}
+
+		name := strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))
+		set[name] = newTexture(img)
+	}
+	return set
+}
+
+func readPNG(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return png.Decode(file)
+}
+
+func newTexture(src image.Image) *Texture {
+	bounds := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	return &Texture{Pixels: rgba.Pix, Width: bounds.Dx(), Height: bounds.Dy()}
+}
+
+// Look up a texture by name, walls without one use the default texture
+func (set Textures) Get(name string) *Texture {
+	if name == "" {
+		name = defaultTextureName
+	}
+	if tex, found := set[name]; found {
+		return tex
+	}
+	return missingTexture
+}
+
+// Floor and ceiling textures for a level, which can name its own in the metadata
+func (set Textures) Surfaces(lvl level.Level) (floor, ceiling *Texture) {
+	floorName, ceilingName := floorTextureName, ceilingTextureName
+	if name := lvl.Meta["floor"]; name != "" {
+		floorName = name
+	}
+	if name := lvl.Meta["ceiling"]; name != "" {
+		ceilingName = name
+	}
+	return set.Get(floorName), set.Get(ceilingName)
+}
+
+// Report textures the walls use that aren't in the set
+func (set Textures) Check(walls []line32) {
+	reported := map[string]bool{}
+	for _, wall := range walls {
+		name := wall.Attrs.Texture
+		if name == "" {
+			name = defaultTextureName
+		}
+		if set[name] == nil && !reported[name] {
+			log.Printf("Missing texture %v, using a checkerboard", name)
+			reported[name] = true
+		}
+	}
+}
+
+// Generate the magenta and black checkerboard used for missing textures
+func checkerboard() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, checkerSize, checkerSize))
+	for y := 0; y < checkerSize; y++ {
+		for x := 0; x < checkerSize; x++ {
+			if (x/checkerSquare+y/checkerSquare)%2 == 0 {
+				img.Set(x, y, color.RGBA{R: 255, B: 255, A: 255})
+			} else {
+				img.Set(x, y, color.Black)
+			}
+		}
+	}
+	return img
+}
+
+// Copy the texel at u,v (0-1 across the texture) into dst, scaled by shade/256
+func (tex *Texture) shadeTexel(dst []byte, u, v float32, shade uint32) {
+	tx := min(int(u*float32(tex.Width)), tex.Width-1)
+	ty := min(int(v*float32(tex.Height)), tex.Height-1)
+	src := tex.Pixels[(ty*tex.Width+tx)*4:]
+
+	dst[0] = byte(uint32(src[0]) * shade >> 8)
+	dst[1] = byte(uint32(src[1]) * shade >> 8)
+	dst[2] = byte(uint32(src[2]) * shade >> 8)
+	dst[3] = src[3]
+}
+
+// Blend the texel at u,v over dst, scaled by shade/256. Transparent texels leave dst alone.
+func (tex *Texture) blendTexel(dst []byte, u, v float32, shade uint32) {
+	tx := min(max(int(u*float32(tex.Width)), 0), tex.Width-1)
+	ty := min(max(int(v*float32(tex.Height)), 0), tex.Height-1)
+	src := tex.Pixels[(ty*tex.Width+tx)*4:]
+
+	alpha := uint32(src[3])
+	if alpha == 0 {
+		return
+	}
+
+	// Premultiplied source over
+	keep := 255 - alpha
+	dst[0] = byte(uint32(src[0])*shade>>8 + uint32(dst[0])*keep/255)
+	dst[1] = byte(uint32(src[1])*shade>>8 + uint32(dst[1])*keep/255)
+	dst[2] = byte(uint32(src[2])*shade>>8 + uint32(dst[2])*keep/255)
+	dst[3] = byte(alpha + uint32(dst[3])*keep/255)
+}
@@ -0,0 +1,117 @@
+package raycast
+
+import "github.com/chewxy/math32"
+
+// Dot product of two 2D vectors
+func DotXY(v1, v2 pos32) float32 {
+	return v1.X*v2.X + v1.Y*v2.Y
+}
+
+// Subtract two vectors
+func SubXY(v1, v2 pos32) pos32 {
+	return pos32{X: v1.X - v2.X, Y: v1.Y - v2.Y}
+}
+
+// Subtract two vectors
+func AddXY(v1, v2 pos32) pos32 {
+	return pos32{X: v1.X + v2.X, Y: v1.Y + v2.Y}
+}
+
+// Scale a vector by a scalar
+func ScaleXY(v pos32, scalar float32) pos32 {
+	return pos32{X: v.X * scalar, Y: v.Y * scalar}
+}
+
+// Normalize a vector
+func NormalizeXY(v pos32) pos32 {
+	magnitude := math32.Sqrt(v.X*v.X + v.Y*v.Y)
+	if magnitude == 0 {
+		return pos32{X: 0, Y: 0}
+	}
+	return pos32{X: v.X / magnitude, Y: v.Y / magnitude}
+}
+
+func movementDirection(wall line32) pos32 {
+	return pos32{
+		X: wall.X2 - wall.X1,
+		Y: wall.Y2 - wall.Y1,
+	}
+}
+
+// Closest point on a wall to p
+func ClosestPointOnSegment(p pos32, wall line32) pos32 {
+	dir := movementDirection(wall)
+	lengthSq := DotXY(dir, dir)
+	if lengthSq == 0 {
+		return pos32{X: wall.X1, Y: wall.Y1}
+	}
+
+	// Project p onto the wall and clamp to its ends
+	t := DotXY(SubXY(p, pos32{X: wall.X1, Y: wall.Y1}), dir) / lengthSq
+	t = math32.Max(0, math32.Min(1, t))
+	return pos32{X: wall.X1 + t*dir.X, Y: wall.Y1 + t*dir.Y}
+}
+
+// 2D cross product, the z of the 3D one
+func crossXY(v1, v2 pos32) float32 {
+	return v1.X*v2.Y - v1.Y*v2.X
+}
+
+// Cast a ray from origin along rayDir against a wall.
+// Returns how many lengths of rayDir away the hit is, and where.
+func RayIntersectsSegment(origin, rayDir pos32, wall line32) (float32, pos32, bool) {
+	// Solve origin + u*rayDir = wallStart + t*wallDir
+	wallDir := movementDirection(wall)
+	denom := crossXY(rayDir, wallDir)
+	if denom == 0 {
+		return 0, pos32{}, false // Parallel lines
+	}
+
+	toWall := pos32{X: wall.X1 - origin.X, Y: wall.Y1 - origin.Y}
+	t := crossXY(toWall, rayDir) / denom
+	u := crossXY(toWall, wallDir) / denom
+
+	// If t and u are valid, we have an intersection
+	if t >= 0 && t <= 1 && u > 0 {
+		// Calculate the intersection point using t
+		intersection := pos32{
+			X: wall.X1 + t*wallDir.X,
+			Y: wall.Y1 + t*wallDir.Y,
+		}
+		return u, intersection, true
+	}
+
+	return 0, pos32{}, false
+}
+
+func BoxToVectors(x, y, width, height float32) []line32 {
+	// Define the four corners of the box
+	topLeft := line32{X1: x, Y1: y, X2: x + width, Y2: y}                       // Top edge
+	topRight := line32{X1: x + width, Y1: y, X2: x + width, Y2: y + height}     // Right edge
+	bottomRight := line32{X1: x + width, Y1: y + height, X2: x, Y2: y + height} // Bottom edge
+	bottomLeft := line32{X1: x, Y1: y + height, X2: x, Y2: y}                   // Left edge
+
+	// Return the four edges of the box
+	return []line32{topLeft, topRight, bottomRight, bottomLeft}
+}
+
+func clipMovement(movement, collisionNormal pos32) pos32 {
+	// Normalize the collision normal
+	normal := NormalizeXY(collisionNormal)
+
+	// Project movement onto the normal (component to block)
+	projection := ScaleXY(normal, DotXY(movement, normal))
+
+	// Subtract projection from the movement to get the clipped movement
+	clippedMovement := SubXY(movement, projection)
+
+	return clippedMovement
+}
+
+// Function to convert an angle in radians to a velocity vector with momentum
+func AngleToXY(angle float32, magnitude float32) pos32 {
+	// Calculate X and Y components using trigonometry
+	vx := magnitude * math32.Cos(angle)
+	vy := magnitude * math32.Sin(angle)
+	return pos32{X: vx, Y: vy}
+}
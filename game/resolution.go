@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"test/raycast"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	minRenderScale = 0.1
+	maxRenderScale = 1
+)
+
+var renderScale = flag.Float64("scale", 1, "render resolution as a fraction of the window, 0.5 for chunky pixels")
+
+// The frame the 3D view is rendered at, before upscaling to the window
+var (
+	frameWidth, frameHeight int
+	frameImage              *ebiten.Image
+)
+
+// Size of the frame for a window, at the render scale
+func frameSize(windowWidth, windowHeight int) (int, int) {
+	scale := min(max(*renderScale, minRenderScale), maxRenderScale)
+	width := max(int(math.Round(float64(windowWidth)*scale)), 1)
+	height := max(int(math.Round(float64(windowHeight)*scale)), 1)
+	return width, height
+}
+
+// Reallocate the frame and everything sized to it if the window changed size.
+// Called from Draw, with renderLock held.
+func resizeFrame(windowWidth, windowHeight int) {
+	width, height := frameSize(windowWidth, windowHeight)
+	if width == frameWidth && height == frameHeight {
+		return
+	}
+	frameWidth, frameHeight = width, height
+
+	if frameImage != nil {
+		frameImage.Deallocate()
+	}
+	frameImage = ebiten.NewImage(width, height)
+
+	framePixels = make([]byte, width*height*4)
+
+	rayList = make([]raycast.RenderData, width)
+	view = raycast.NewCamera(FOVDeg, width)
+	workSize = pool.chunkSize(width)
+}
+
+// Draw the frame over the whole window, keeping pixels sharp
+func drawFrame(screen *ebiten.Image) {
+	bounds := screen.Bounds()
+	op := &ebiten.DrawImageOptions{Filter: ebiten.FilterNearest}
+	op.GeoM.Scale(float64(bounds.Dx())/float64(frameWidth), float64(bounds.Dy())/float64(frameHeight))
+	screen.DrawImage(frameImage, op)
+}
+
+// Toggle fullscreen with F11 or Alt+Enter
+func updateFullscreen() {
+	altEnter := ebiten.IsKeyPressed(ebiten.KeyAlt) && inpututil.IsKeyJustPressed(ebiten.KeyEnter)
+	if inpututil.IsKeyJustPressed(ebiten.KeyF11) || altEnter {
+		ebiten.SetFullscreen(!ebiten.IsFullscreen())
+	}
+}
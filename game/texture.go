@@ -0,0 +1,29 @@
+package main
+
+import (
+	"image"
+	"test/raycast"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const textureDir = "textures"
+
+var (
+	// Only written while loading, before rendering starts
+	textures raycast.Textures
+
+	// GPU copies of the textures, made the first time each is drawn
+	textureImages = map[*raycast.Texture]*ebiten.Image{}
+)
+
+// The GPU copy of a texture, uploaded the first time it's drawn
+func textureImage(tex *raycast.Texture) *ebiten.Image {
+	img := textureImages[tex]
+	if img == nil {
+		rgba := &image.RGBA{Pix: tex.Pixels, Stride: tex.Width * 4, Rect: image.Rect(0, 0, tex.Width, tex.Height)}
+		img = ebiten.NewImageFromImage(rgba)
+		textureImages[tex] = img
+	}
+	return img
+}
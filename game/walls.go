@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"test/raycast"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Most vertices one DrawTriangles call can index with uint16
+const maxBatchVertices = 1 << 16
+
+var cpuWalls = flag.Bool("cpuwalls", false, "draw walls into the CPU framebuffer instead of on the GPU")
+
+// Wall slices sharing a texture, drawn with one DrawTriangles call
+type wallBatch struct {
+	vertices []ebiten.Vertex
+	indices  []uint16
+}
+
+// Reused every frame, so batching doesn't allocate once they've grown
+var wallBatches = map[*raycast.Texture]*wallBatch{}
+
+// Draw every solid wall slice in rayList, batched by texture
+func renderWallSlice(screen *ebiten.Image) {
+	for col := range rayList {
+		data := &rayList[col]
+		for i := range data.Slices {
+			batchSlice(screen, data.X, &data.Slices[i])
+		}
+	}
+	drawBatches(screen)
+}
+
+// Draw the masked walls and sprites over the walls, farthest first in every column.
+// Every column's farthest layer is drawn before any column's next one, so each layer of
+// the frame can be batched by texture: its slices are all in different columns.
+func renderLayers(screen *ebiten.Image) {
+	for layer := 0; ; layer++ {
+		found := false
+		for col := range rayList {
+			data := &rayList[col]
+			if layer < len(data.Layers) {
+				batchSlice(screen, data.X, &data.Layers[layer])
+				found = true
+			}
+		}
+		if !found {
+			return
+		}
+		drawBatches(screen)
+	}
+}
+
+// Add a slice to the batch for its texture, drawing the batch first if it's full
+func batchSlice(screen *ebiten.Image, x int, slice *raycast.WallSlice) {
+	batch := wallBatches[slice.Tex]
+	if batch == nil {
+		batch = &wallBatch{}
+		wallBatches[slice.Tex] = batch
+	}
+	if len(batch.vertices)+4 > maxBatchVertices {
+		batch.draw(screen, slice.Tex)
+	}
+	batch.addSlice(x, slice)
+}
+
+// Draw and empty every batch
+func drawBatches(screen *ebiten.Image) {
+	for tex, batch := range wallBatches {
+		batch.draw(screen, tex)
+	}
+}
+
+// Add a one pixel wide quad for the drawn rows of a slice
+func (b *wallBatch) addSlice(x int, slice *raycast.WallSlice) {
+	tex := slice.Tex
+	x0, x1 := float32(x), float32(x+1)
+	y0, y1 := float32(slice.ClipTop), float32(slice.ClipBottom)
+
+	textureX := min(int(slice.U*float32(tex.Width)), tex.Width-1)
+	u0, u1 := float32(textureX), float32(textureX+1)
+
+	// Texture rows past the bottom repeat, for walls taller than one texture
+	v0 := slice.TextureV(y0) * float32(tex.Height)
+	v1 := slice.TextureV(y1) * float32(tex.Height)
+
+	b.addQuad(x0, y0, x1, y1, u0, v0, u1, v1, slice.ValFloat)
+}
+
+// Add a screen rectangle showing a rectangle of texels, shaded through the vertex colour
+func (b *wallBatch) addQuad(x0, y0, x1, y1, u0, v0, u1, v1, shade float32) {
+	base := uint16(len(b.vertices))
+	b.vertices = append(b.vertices,
+		ebiten.Vertex{DstX: x0, DstY: y0, SrcX: u0, SrcY: v0, ColorR: shade, ColorG: shade, ColorB: shade, ColorA: 1},
+		ebiten.Vertex{DstX: x1, DstY: y0, SrcX: u1, SrcY: v0, ColorR: shade, ColorG: shade, ColorB: shade, ColorA: 1},
+		ebiten.Vertex{DstX: x0, DstY: y1, SrcX: u0, SrcY: v1, ColorR: shade, ColorG: shade, ColorB: shade, ColorA: 1},
+		ebiten.Vertex{DstX: x1, DstY: y1, SrcX: u1, SrcY: v1, ColorR: shade, ColorG: shade, ColorB: shade, ColorA: 1},
+	)
+	b.indices = append(b.indices, base, base+1, base+2, base+1, base+3, base+2)
+}
+
+// Draw and empty the batch
+func (b *wallBatch) draw(screen *ebiten.Image, tex *raycast.Texture) {
+	if len(b.indices) == 0 {
+		return
+	}
+	op := &ebiten.DrawTrianglesOptions{Filter: ebiten.FilterNearest, Address: ebiten.AddressRepeat}
+	screen.DrawTriangles(b.vertices, b.indices, textureImage(tex), op)
+	b.vertices = b.vertices[:0]
+	b.indices = b.indices[:0]
+}
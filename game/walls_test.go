@@ -0,0 +1,133 @@
+package main
+
+import (
+	"test/raycast"
+	"testing"
+)
+
+// Set up a frame and cast every column, ready to draw walls
+func setupWallGlobals(t testing.TB) {
+	setupSceneGlobals(t, 4)
+	framePixels = make([]byte, frameWidth*frameHeight*4)
+	aimScene()
+	castScene()
+}
+
+// Build the vertices renderWallSlice and renderLayers would draw, then empty the batches.
+// Outside a running game DrawTriangles only queues the call, so this is the CPU side of a frame.
+func batchWallVertices() {
+	for col := range rayList {
+		data := &rayList[col]
+		for i := range data.Slices {
+			addToBatch(data.X, &data.Slices[i])
+		}
+		for i := range data.Layers {
+			addToBatch(data.X, &data.Layers[i])
+		}
+	}
+	for _, batch := range wallBatches {
+		batch.vertices = batch.vertices[:0]
+		batch.indices = batch.indices[:0]
+	}
+}
+
+// Add a slice to the batch for its texture, emptying the batch first if it's full
+func addToBatch(x int, slice *raycast.WallSlice) {
+	batch := wallBatches[slice.Tex]
+	if batch == nil {
+		batch = &wallBatch{}
+		wallBatches[slice.Tex] = batch
+	}
+	if len(batch.vertices)+4 > maxBatchVertices {
+		batch.vertices = batch.vertices[:0]
+		batch.indices = batch.indices[:0]
+	}
+	batch.addSlice(x, slice)
+}
+
+// Shade the walls, masked walls and sprites of a range of columns, as -cpuwalls does
+func shadeWallColumns(start, end int) {
+	for col := start; col < end; col++ {
+		sceneFrame.ShadeWalls(&rayList[col])
+	}
+}
+
+func TestWallBatchAddSlice(t *testing.T) {
+	tex := &raycast.Texture{Width: 64, Height: 64}
+
+	tests := []struct {
+		name        string
+		slice       raycast.WallSlice
+		wantTop     float32
+		wantBottom  float32
+		wantV0      float32
+		wantV1      float32
+		wantTexelX0 float32
+	}{
+		{
+			name:    "on screen",
+			slice:   raycast.WallSlice{Top: 310, Bottom: 410, ClipTop: 310, ClipBottom: 410, U: 0.1, V0: 0, V1: 1},
+			wantTop: 310, wantBottom: 410, wantV0: 0, wantV1: 64, wantTexelX0: 6,
+		},
+		{
+			// Taller than the screen, the quad covers the screen with the texture clipped to match
+			name:    "clipped",
+			slice:   raycast.WallSlice{Top: -360, Bottom: 1080, ClipTop: 0, ClipBottom: 720, U: 0.5, V0: 0, V1: 1},
+			wantTop: 0, wantBottom: 720, wantV0: 16, wantV1: 48, wantTexelX0: 32,
+		},
+		{
+			// Two wall heights tall, the texture repeats
+			name:    "repeated",
+			slice:   raycast.WallSlice{Top: 100, Bottom: 500, ClipTop: 100, ClipBottom: 500, U: 1, V0: 0, V1: 2},
+			wantTop: 100, wantBottom: 500, wantV0: 0, wantV1: 128, wantTexelX0: 63,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.slice.Tex = tex
+			tt.slice.ValFloat = 0.5
+
+			var batch wallBatch
+			batch.addSlice(7, &tt.slice)
+
+			if len(batch.vertices) != 4 || len(batch.indices) != 6 {
+				t.Fatalf("%v vertices and %v indices, want 4 and 6", len(batch.vertices), len(batch.indices))
+			}
+			top, bottom := batch.vertices[0], batch.vertices[3]
+			if top.DstX != 7 || bottom.DstX != 8 {
+				t.Errorf("quad spans x %v to %v, want 7 to 8", top.DstX, bottom.DstX)
+			}
+			if top.DstY != tt.wantTop || bottom.DstY != tt.wantBottom {
+				t.Errorf("quad spans y %v to %v, want %v to %v", top.DstY, bottom.DstY, tt.wantTop, tt.wantBottom)
+			}
+			if top.SrcY != tt.wantV0 || bottom.SrcY != tt.wantV1 {
+				t.Errorf("texture spans v %v to %v, want %v to %v", top.SrcY, bottom.SrcY, tt.wantV0, tt.wantV1)
+			}
+			if top.SrcX != tt.wantTexelX0 || bottom.SrcX != tt.wantTexelX0+1 {
+				t.Errorf("texture spans u %v to %v, want %v to %v", top.SrcX, bottom.SrcX, tt.wantTexelX0, tt.wantTexelX0+1)
+			}
+			if top.ColorR != 0.5 || top.ColorA != 1 {
+				t.Errorf("vertex colour %v,%v, want shade 0.5 and alpha 1", top.ColorR, top.ColorA)
+			}
+		})
+	}
+}
+
+// The GPU path builds vertices on one goroutine, the -cpuwalls path shades every texel on the pool
+func BenchmarkRenderWalls(b *testing.B) {
+	setupWallGlobals(b)
+
+	b.Run("batched", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			batchWallVertices()
+		}
+	})
+	b.Run("cpu", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			pool.run(frameWidth, workSize, shadeWallColumns)
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"runtime"
+	"sync"
+)
+
+var workerCount = flag.Int("workers", runtime.NumCPU(), "number of render worker goroutines")
+
+// Long-lived goroutines that share out each frame's rendering
+var pool *workerPool
+
+// A range of columns or rows for one worker
+type poolJob struct {
+	fn         func(start, end int)
+	start, end int
+}
+
+type workerPool struct {
+	workers int
+	jobs    chan poolJob
+	wg      sync.WaitGroup
+}
+
+// Start a pool, the workers live until the program exits
+func newWorkerPool(workers int) *workerPool {
+	workers = max(workers, 1)
+	p := &workerPool{workers: workers, jobs: make(chan poolJob, workers*4)}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *workerPool) work() {
+	for job := range p.jobs {
+		job.fn(job.start, job.end)
+		p.wg.Done()
+	}
+}
+
+// Split 0..total into chunks, run fn on each across the workers, and wait for them all.
+// fn should be a plain function, not a closure, so dispatching doesn't allocate.
+func (p *workerPool) run(total, chunk int, fn func(start, end int)) {
+	chunk = max(chunk, 1)
+	for start := 0; start < total; start += chunk {
+		p.wg.Add(1)
+		p.jobs <- poolJob{fn: fn, start: start, end: min(start+chunk, total)}
+	}
+	p.wg.Wait()
+}
+
+// Chunk size giving each worker a couple of jobs, so a slow chunk doesn't hold up the frame
+func (p *workerPool) chunkSize(total int) int {
+	return max(total/(p.workers*2), 1)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"test/raycast"
+	"testing"
+
+	"github.com/chewxy/math32"
+)
+
+// Set up the globals castScene uses, for the level start
+func setupSceneGlobals(t testing.TB, workers int) {
+	textures = raycast.LoadTextures(textureDir)
+	lvl := loadTestLevel(t)
+
+	bspData = raycast.BuildBSPTree(lvl.Walls)
+	player = playerData{pos: lvl.Start, angle: -math32.Pi / 2}
+
+	pool = newWorkerPool(workers)
+	frameWidth, frameHeight = screenWidth, screenHeight
+	rayList = make([]raycast.RenderData, frameWidth)
+	view = raycast.NewCamera(FOVDeg, frameWidth)
+	workSize = pool.chunkSize(frameWidth)
+	aimScene()
+}
+
+func TestCastColumnsEveryColumn(t *testing.T) {
+	setupSceneGlobals(t, 3)
+
+	for i := range rayList {
+		rayList[i] = raycast.RenderData{X: -1}
+	}
+	pool.run(frameWidth, workSize, castColumns)
+
+	for col, data := range rayList {
+		if data.X != col || len(data.Slices) == 0 {
+			t.Fatalf("column %v was not cast", col)
+		}
+	}
+}
+
+func TestWorkerPoolNoAllocs(t *testing.T) {
+	setupSceneGlobals(t, 4)
+
+	allocs := testing.AllocsPerRun(20, func() {
+		pool.run(frameWidth, workSize, castColumns)
+	})
+	if allocs > 0 {
+		t.Errorf("%v allocations per frame, want none", allocs)
+	}
+}
+
+// The old renderScene, a goroutine for every chunk of every frame
+func castColumnsSpawning(chunk int) {
+	var wg sync.WaitGroup
+	for x := 0; x < frameWidth; x += chunk {
+		wg.Add(1)
+		go func(start int) {
+			castColumns(start, min(start+chunk, frameWidth))
+			wg.Done()
+		}(x)
+	}
+	wg.Wait()
+}
+
+func BenchmarkCastColumns(b *testing.B) {
+	for _, cores := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("pool/%v", cores), func(b *testing.B) {
+			defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(cores))
+			setupSceneGlobals(b, cores)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				pool.run(frameWidth, workSize, castColumns)
+			}
+		})
+		b.Run(fmt.Sprintf("spawn/%v", cores), func(b *testing.B) {
+			defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(cores))
+			setupSceneGlobals(b, cores)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				castColumnsSpawning(workSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,355 @@
+package level
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+)
+
+// IssueKind is a problem with how a wall fits into the level's loops
+type IssueKind int
+
+const (
+	ZeroLength IssueKind = iota // The wall starts and ends at the same point
+	OpenEnd                     // An end of the wall doesn't meet any other wall
+	Dangling                    // The wall isn't part of any closed loop
+)
+
+func (kind IssueKind) String() string {
+	switch kind {
+	case ZeroLength:
+		return "has zero length"
+	case OpenEnd:
+		return "has an open end"
+	case Dangling:
+		return "isn't part of a closed loop"
+	}
+	return fmt.Sprintf("IssueKind(%d)", int(kind))
+}
+
+// Issue is a wall that doesn't close a loop, found by Detect
+type Issue struct {
+	Kind  IssueKind
+	Wall  int   // Index of the wall in the level
+	Point Pos32 // Where to point the problem out, the open end for OpenEnd
+}
+
+func (issue Issue) String() string {
+	return fmt.Sprintf("wall %v at %v,%v %v", issue.Wall, formatCoord(issue.Point.X), formatCoord(issue.Point.Y), issue.Kind)
+}
+
+// Topology is the result of Detect
+type Topology struct {
+	Sectors []Sector // One for every area enclosed by walls, at the default heights
+	Issues  []Issue
+}
+
+// How far off a wall, relative to its length, another wall's end may be and still join it
+const joinTolerance = 1e-4
+
+// An edge of the wall graph, between two vertices
+type edge struct {
+	a, b int
+	wall int
+}
+
+// Detect links the ends of the walls into closed loops and returns a sector for every area
+// they enclose. Loops inside an area that aren't joined to its walls, such as pillars, become
+// holes in its sector. Walls are joined where they share an end, or where one ends on another.
+func Detect(walls []Line32) Topology {
+	var topo Topology
+	var points []Pos32
+	index := map[Pos32]int{}
+	vertex := func(p Pos32) int {
+		if i, ok := index[p]; ok {
+			return i
+		}
+		index[p] = len(points)
+		points = append(points, p)
+		return len(points) - 1
+	}
+
+	for i, wall := range walls {
+		if wall.X1 == wall.X2 && wall.Y1 == wall.Y2 {
+			topo.Issues = append(topo.Issues, Issue{Kind: ZeroLength, Wall: i, Point: Pos32{X: wall.X1, Y: wall.Y1}})
+			continue
+		}
+		vertex(Pos32{X: wall.X1, Y: wall.Y1})
+		vertex(Pos32{X: wall.X2, Y: wall.Y2})
+	}
+
+	// Split the walls at the ends of other walls that meet them partway along
+	var edges []edge
+	seen := map[[2]int]bool{}
+	for i, wall := range walls {
+		if wall.X1 == wall.X2 && wall.Y1 == wall.Y2 {
+			continue
+		}
+
+		type stop struct {
+			t float32
+			v int
+		}
+		stops := []stop{{0, index[Pos32{X: wall.X1, Y: wall.Y1}]}, {1, index[Pos32{X: wall.X2, Y: wall.Y2}]}}
+		dx, dy := wall.X2-wall.X1, wall.Y2-wall.Y1
+		lengthSq := dx*dx + dy*dy
+		for v, p := range points {
+			t := ((p.X-wall.X1)*dx + (p.Y-wall.Y1)*dy) / lengthSq
+			cross := (p.X-wall.X1)*dy - (p.Y-wall.Y1)*dx
+			if t > 0 && t < 1 && float32(math.Abs(float64(cross))) <= joinTolerance*lengthSq {
+				stops = append(stops, stop{t, v})
+			}
+		}
+		sort.Slice(stops, func(a, b int) bool { return stops[a].t < stops[b].t })
+
+		for s := 1; s < len(stops); s++ {
+			a, b := stops[s-1].v, stops[s].v
+			key := [2]int{min(a, b), max(a, b)}
+			if a == b || seen[key] {
+				continue
+			}
+			seen[key] = true
+			edges = append(edges, edge{a: a, b: b, wall: i})
+		}
+	}
+
+	edges, issues := pruneOpenEdges(points, edges, walls)
+	topo.Issues = append(topo.Issues, issues...)
+
+	// Walk the faces of the remaining graph, turning the same way at every vertex
+	var rooms, outlines [][]int
+	for _, face := range traceFaces(points, edges) {
+		area := polygonArea(points, face)
+		if area > 0 {
+			rooms = append(rooms, face)
+		} else if area < 0 {
+			outlines = append(outlines, face)
+		}
+	}
+
+	component := components(len(points), edges)
+	for _, room := range rooms {
+		sec := Sector{Points: polygonPoints(points, room), Floor: DefaultFloor, Ceiling: DefaultCeiling}
+		topo.Sectors = append(topo.Sectors, sec)
+	}
+
+	// Each separate group of walls is a hole in the smallest room of another group around it
+	for _, outline := range outlines {
+		inside := -1
+		for r, room := range rooms {
+			if component[room[0]] == component[outline[0]] || !topo.Sectors[r].Contains(points[outline[0]]) {
+				continue
+			}
+			if inside < 0 || polygonArea(points, room) < polygonArea(points, rooms[inside]) {
+				inside = r
+			}
+		}
+		if inside >= 0 {
+			topo.Sectors[inside].Holes = append(topo.Sectors[inside].Holes, polygonPoints(points, outline))
+		}
+	}
+	return topo
+}
+
+// Remove the edges that can't be part of a loop, and report the walls left with none
+func pruneOpenEdges(points []Pos32, edges []edge, walls []Line32) ([]edge, []Issue) {
+	degree := make([]int, len(points))
+	for _, e := range edges {
+		degree[e.a]++
+		degree[e.b]++
+	}
+
+	var issues []Issue
+	reported := map[int]bool{}
+	for _, e := range edges {
+		for _, v := range []int{e.a, e.b} {
+			if degree[v] == 1 && !reported[e.wall] {
+				issues = append(issues, Issue{Kind: OpenEnd, Wall: e.wall, Point: points[v]})
+				reported[e.wall] = true
+			}
+		}
+	}
+
+	// Keep cutting edges off open ends until only loops and the links between them are left
+	removed := make([]bool, len(edges))
+	for changed := true; changed; {
+		changed = false
+		for i, e := range edges {
+			if !removed[i] && (degree[e.a] == 1 || degree[e.b] == 1) {
+				removed[i] = true
+				degree[e.a]--
+				degree[e.b]--
+				changed = true
+			}
+		}
+	}
+
+	kept := map[int]bool{}
+	for i, e := range edges {
+		if !removed[i] {
+			kept[e.wall] = true
+		}
+	}
+	for i, e := range edges {
+		if removed[i] && !kept[e.wall] && !reported[e.wall] {
+			wall := walls[e.wall]
+			issues = append(issues, Issue{Kind: Dangling, Wall: e.wall, Point: Pos32{X: (wall.X1 + wall.X2) / 2, Y: (wall.Y1 + wall.Y2) / 2}})
+			reported[e.wall] = true
+		}
+	}
+
+	var loops []edge
+	for i, e := range edges {
+		if !removed[i] {
+			loops = append(loops, e)
+		}
+	}
+	return loops, issues
+}
+
+// Follow every edge in both directions around the face on its left, returning each face once
+func traceFaces(points []Pos32, edges []edge) [][]int {
+	// Neighbours of each vertex, sorted by the direction to them
+	neighbours := make([][]int, len(points))
+	for _, e := range edges {
+		neighbours[e.a] = append(neighbours[e.a], e.b)
+		neighbours[e.b] = append(neighbours[e.b], e.a)
+	}
+	for v, list := range neighbours {
+		angle := func(n int) float64 {
+			return math.Atan2(float64(points[n].Y-points[v].Y), float64(points[n].X-points[v].X))
+		}
+		sort.Slice(list, func(a, b int) bool { return angle(list[a]) < angle(list[b]) })
+	}
+
+	visited := map[[2]int]bool{}
+	var faces [][]int
+	for _, e := range edges {
+		for _, start := range [][2]int{{e.a, e.b}, {e.b, e.a}} {
+			if visited[start] {
+				continue
+			}
+
+			var face []int
+			for half := start; !visited[half]; {
+				visited[half] = true
+				face = append(face, half[0])
+
+				// Leave the far vertex by the edge next around from the one we came in on
+				from, at := half[0], half[1]
+				list := neighbours[at]
+				i := 0
+				for list[i] != from {
+					i++
+				}
+				half = [2]int{at, list[(i+len(list)-1)%len(list)]}
+			}
+			faces = append(faces, face)
+		}
+	}
+	return faces
+}
+
+// Label each vertex with the group of walls it's joined to
+func components(count int, edges []edge) []int {
+	parent := make([]int, count)
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(v int) int {
+		if parent[v] != v {
+			parent[v] = find(parent[v])
+		}
+		return parent[v]
+	}
+
+	for _, e := range edges {
+		parent[find(e.a)] = find(e.b)
+	}
+	for v := range parent {
+		parent[v] = find(v)
+	}
+	return parent
+}
+
+// Signed area of a polygon of vertices, positive for the faces traceFaces finds inside loops
+func polygonArea(points []Pos32, polygon []int) float32 {
+	var area float32
+	for i, v := range polygon {
+		a, b := points[v], points[polygon[(i+1)%len(polygon)]]
+		area += a.X*b.Y - b.X*a.Y
+	}
+	return area / 2
+}
+
+func polygonPoints(points []Pos32, polygon []int) []Pos32 {
+	out := make([]Pos32, len(polygon))
+	for i, v := range polygon {
+		out[i] = points[v]
+	}
+	return out
+}
+
+// DetectSectors returns a copy of the level with a sector for every area its walls enclose
+// that isn't already declared, along with the problems found in its walls. The found sectors
+// come before the declared ones, so the declared ones take precedence where they overlap.
+// A declared sector with the same corners as a found one gets its holes.
+// Closed doors bound sectors like walls, but problems with them aren't reported.
+func (lvl Level) DetectSectors() (Level, []Issue) {
+	topo := Detect(lvl.Boundaries())
+	topo.Issues = slices.DeleteFunc(topo.Issues, func(issue Issue) bool { return issue.Wall >= len(lvl.Walls) })
+
+	declared := make([]Sector, len(lvl.Sectors))
+	copy(declared, lvl.Sectors)
+
+	var found []Sector
+	for _, sec := range topo.Sectors {
+		match := -1
+		for i := range declared {
+			if SamePolygon(declared[i].Points, sec.Points) {
+				match = i
+				break
+			}
+		}
+		if match < 0 {
+			found = append(found, sec)
+		} else if len(declared[match].Holes) == 0 {
+			declared[match].Holes = sec.Holes
+		}
+	}
+
+	lvl.Sectors = append(found, declared...)
+	return lvl, topo.Issues
+}
+
+// Boundaries returns the lines sectors are detected from: the walls, followed by the doors
+// where they are closed
+func (lvl Level) Boundaries() []Line32 {
+	lines := make([]Line32, len(lvl.Walls), len(lvl.Walls)+len(lvl.Doors))
+	copy(lines, lvl.Walls)
+	for _, door := range lvl.Doors {
+		lines = append(lines, door.Wall)
+	}
+	return lines
+}
+
+// SamePolygon reports whether two polygons have the same corners in the same order,
+// from any start and in either direction
+func SamePolygon(a, b []Pos32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for start := range b {
+		forward, backward := true, true
+		for i := range a {
+			forward = forward && a[i] == b[(start+i)%len(b)]
+			backward = backward && a[i] == b[(start-i+len(b))%len(b)]
+		}
+		if forward || backward {
+			return true
+		}
+	}
+	return false
+}
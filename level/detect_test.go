@@ -0,0 +1,220 @@
+package level
+
+import (
+	"testing"
+)
+
+// Walls around a polygon, each corner joined to the next
+func loopWalls(points ...Pos32) []Line32 {
+	walls := make([]Line32, len(points))
+	for i, a := range points {
+		b := points[(i+1)%len(points)]
+		walls[i] = Line32{X1: a.X, Y1: a.Y, X2: b.X, Y2: b.Y}
+	}
+	return walls
+}
+
+func TestDetectRoom(t *testing.T) {
+	walls := loopWalls(Pos32{X: 0, Y: 0}, Pos32{X: 10, Y: 0}, Pos32{X: 10, Y: 10}, Pos32{X: 0, Y: 10})
+
+	// The same room with its walls running the other way round
+	reversed := make([]Line32, len(walls))
+	for i, wall := range walls {
+		reversed[i] = Line32{X1: wall.X2, Y1: wall.Y2, X2: wall.X1, Y2: wall.Y1}
+	}
+
+	for name, walls := range map[string][]Line32{"forwards": walls, "backwards": reversed} {
+		topo := Detect(walls)
+		if len(topo.Issues) != 0 {
+			t.Errorf("%v: issues %v, want none", name, topo.Issues)
+		}
+		if len(topo.Sectors) != 1 {
+			t.Fatalf("%v: got %v sectors, want 1", name, len(topo.Sectors))
+		}
+		sec := topo.Sectors[0]
+		if len(sec.Points) != 4 || !sec.Contains(Pos32{X: 5, Y: 5}) || sec.Contains(Pos32{X: 15, Y: 5}) {
+			t.Errorf("%v: sector %v doesn't cover the room", name, sec)
+		}
+		if sec.Floor != DefaultFloor || sec.Ceiling != DefaultCeiling {
+			t.Errorf("%v: sector heights %v,%v, want the defaults", name, sec.Floor, sec.Ceiling)
+		}
+	}
+}
+
+func TestDetectPillar(t *testing.T) {
+	walls := append(
+		loopWalls(Pos32{X: 0, Y: 0}, Pos32{X: 10, Y: 0}, Pos32{X: 10, Y: 10}, Pos32{X: 0, Y: 10}),
+		loopWalls(Pos32{X: 4, Y: 4}, Pos32{X: 6, Y: 4}, Pos32{X: 6, Y: 6}, Pos32{X: 4, Y: 6})...,
+	)
+
+	topo := Detect(walls)
+	if len(topo.Sectors) != 2 {
+		t.Fatalf("got %v sectors, want the room and the pillar", len(topo.Sectors))
+	}
+	for _, sec := range topo.Sectors {
+		if len(sec.Holes) == 1 {
+			if sec.Contains(Pos32{X: 5, Y: 5}) || !sec.Contains(Pos32{X: 2, Y: 2}) {
+				t.Errorf("room %v doesn't have the pillar cut out", sec)
+			}
+		} else if len(sec.Holes) != 0 || !sec.Contains(Pos32{X: 5, Y: 5}) {
+			t.Errorf("pillar %v doesn't cover the pillar", sec)
+		}
+	}
+}
+
+func TestDetectSplitRooms(t *testing.T) {
+	// A dividing wall that ends partway along the outer walls splits the room in two
+	walls := append(
+		loopWalls(Pos32{X: 0, Y: 0}, Pos32{X: 10, Y: 0}, Pos32{X: 10, Y: 10}, Pos32{X: 0, Y: 10}),
+		Line32{X1: 5, Y1: 0, X2: 5, Y2: 10},
+	)
+
+	topo := Detect(walls)
+	if len(topo.Issues) != 0 {
+		t.Errorf("issues %v, want none", topo.Issues)
+	}
+	if len(topo.Sectors) != 2 {
+		t.Fatalf("got %v sectors, want 2", len(topo.Sectors))
+	}
+	left, right := Pos32{X: 2, Y: 5}, Pos32{X: 8, Y: 5}
+	for _, sec := range topo.Sectors {
+		if sec.Contains(left) == sec.Contains(right) {
+			t.Errorf("sector %v isn't on one side of the dividing wall", sec)
+		}
+	}
+}
+
+func TestDetectIssues(t *testing.T) {
+	walls := append(
+		loopWalls(Pos32{X: 0, Y: 0}, Pos32{X: 10, Y: 0}, Pos32{X: 10, Y: 10}, Pos32{X: 0, Y: 10}),
+		Line32{X1: 3, Y1: 3, X2: 3, Y2: 3},   // 4: zero length
+		Line32{X1: 20, Y1: 0, X2: 30, Y2: 0}, // 5: open at both ends
+		Line32{X1: 10, Y1: 10, X2: 15, Y2: 15},
+		Line32{X1: 15, Y1: 15, X2: 20, Y2: 15}, // 7: the open end of a chain off the room
+	)
+
+	want := map[int]Issue{
+		4: {Kind: ZeroLength, Wall: 4, Point: Pos32{X: 3, Y: 3}},
+		5: {Kind: OpenEnd, Wall: 5, Point: Pos32{X: 20, Y: 0}},
+		6: {Kind: Dangling, Wall: 6, Point: Pos32{X: 12.5, Y: 12.5}},
+		7: {Kind: OpenEnd, Wall: 7, Point: Pos32{X: 20, Y: 15}},
+	}
+
+	topo := Detect(walls)
+	if len(topo.Issues) != len(want) {
+		t.Errorf("issues %v, want %v", topo.Issues, want)
+	}
+	for _, issue := range topo.Issues {
+		if issue != want[issue.Wall] {
+			t.Errorf("issue %v, want %v", issue, want[issue.Wall])
+		}
+	}
+	if len(topo.Sectors) != 1 {
+		t.Errorf("got %v sectors, want 1", len(topo.Sectors))
+	}
+}
+
+func TestDetectLevelFiles(t *testing.T) {
+	tests := []struct {
+		path       string
+		wantIssues []Issue
+		wantCounts map[Pos32]int // How many found sectors points should be in
+	}{
+		// The gap at 526,615 to 571,615 is a doorway, the walls either side of it go on to close the room
+		{"../level1.txt", []Issue{{Kind: ZeroLength, Wall: 43, Point: Pos32{X: 806, Y: 496}}}, map[Pos32]int{
+			{X: 548, Y: 742}: 1, // Start
+			{X: 548, Y: 600}: 1, // Doorway
+			{X: 0, Y: 0}:     0,
+		}},
+		// The corners are cut off by walls ending partway along the outer walls, the pillar is cut out of the room
+		{"../level2.txt", []Issue{{Kind: ZeroLength, Wall: 5, Point: Pos32{X: 1800, Y: 25}}}, map[Pos32]int{
+			{X: 500, Y: 500}: 1, // Room
+			{X: 940, Y: 500}: 1, // Pillar
+			{X: 30, Y: 30}:   1, // Corner
+			{X: 0, Y: 0}:     0,
+		}},
+	}
+
+	for _, tt := range tests {
+		lvl, err := Read(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		topo := Detect(lvl.Walls)
+		if len(topo.Issues) != len(tt.wantIssues) {
+			t.Errorf("%v: issues %v, want %v", tt.path, topo.Issues, tt.wantIssues)
+		} else {
+			for i := range tt.wantIssues {
+				if topo.Issues[i] != tt.wantIssues[i] {
+					t.Errorf("%v: issue %v, want %v", tt.path, topo.Issues[i], tt.wantIssues[i])
+				}
+			}
+		}
+
+		for p, want := range tt.wantCounts {
+			count := 0
+			for _, sec := range topo.Sectors {
+				if sec.Contains(p) {
+					count++
+				}
+			}
+			if count != want {
+				t.Errorf("%v: %v is in %v sectors, want %v", tt.path, p, count, want)
+			}
+		}
+	}
+}
+
+func TestDetectSectors(t *testing.T) {
+	room := []Pos32{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}}
+	pillar := []Pos32{{X: 4, Y: 4}, {X: 6, Y: 4}, {X: 6, Y: 6}, {X: 4, Y: 6}}
+	lvl := Level{
+		Walls: append(loopWalls(room...), loopWalls(pillar...)...),
+
+		// The room declared from a different corner and the other way round
+		Sectors: []Sector{{Points: []Pos32{room[2], room[1], room[0], room[3]}, Floor: 0.25, Ceiling: 2, Tag: 3}},
+	}
+
+	detected, issues := lvl.DetectSectors()
+	if len(issues) != 0 {
+		t.Errorf("issues %v, want none", issues)
+	}
+	if len(detected.Sectors) != 2 {
+		t.Fatalf("got %v sectors, want the pillar and the declared room", len(detected.Sectors))
+	}
+
+	// The pillar is found, and comes before the declared room
+	if pillar := detected.Sectors[0]; pillar.Floor != DefaultFloor || !pillar.Contains(Pos32{X: 5, Y: 5}) {
+		t.Errorf("first sector = %v, want the pillar", pillar)
+	}
+	declared := detected.Sectors[1]
+	if declared.Tag != 3 || declared.Floor != 0.25 || len(declared.Holes) != 1 {
+		t.Errorf("declared sector = %v, want its own heights and the pillar as a hole", declared)
+	}
+	if len(lvl.Sectors[0].Holes) != 0 {
+		t.Error("detecting sectors changed the original level")
+	}
+}
+
+func TestDetectSectorsDoors(t *testing.T) {
+	// A dividing wall with a doorway in it, closed by a door
+	lvl := Level{
+		Walls: append(loopWalls(Pos32{X: 0, Y: 0}, Pos32{X: 10, Y: 0}, Pos32{X: 10, Y: 10}, Pos32{X: 0, Y: 10}),
+			Line32{X1: 0, Y1: 5, X2: 4, Y2: 5},
+			Line32{X1: 6, Y1: 5, X2: 10, Y2: 5},
+		),
+		Doors: []Door{{Wall: Line32{X1: 4, Y1: 5, X2: 6, Y2: 5}}},
+	}
+
+	detected, issues := lvl.DetectSectors()
+	if len(issues) != 0 {
+		t.Errorf("issues %v, want none", issues)
+	}
+	if len(detected.Sectors) != 2 {
+		t.Fatalf("got %v sectors, want the rooms either side of the door", len(detected.Sectors))
+	}
+	if len(detected.Walls) != len(lvl.Walls) {
+		t.Errorf("%v walls after detecting, want %v", len(detected.Walls), len(lvl.Walls))
+	}
+}
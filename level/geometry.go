@@ -0,0 +1,105 @@
+package level
+
+import "image/color"
+
+// Line32 is a wall segment from X1,Y1 to X2,Y2
+type Line32 struct {
+	X1, Y1, X2, Y2 float32
+	Offset         float32 // Distance along the original wall, for walls split by the BSP
+	Attrs          WallAttrs
+}
+
+// WallAttrs are the per-wall settings stored in the level file
+type WallAttrs struct {
+	Texture  string      // Texture name, empty for the default texture
+	Color    color.NRGBA // Tint, zero for none
+	Height   float32     // Wall height, 0 for the default full height
+	TwoSided bool        // Whether the wall can be seen through from either side
+	Masked   bool        // Whether farther walls show through the transparent texels of its texture
+	Passable bool        // Whether the player can walk through a masked wall
+	Tag      int         // Links the wall to triggers and scripts
+}
+
+// Pos32 is a point in the level
+type Pos32 struct {
+	X, Y float32
+}
+
+// Sprite is a camera-facing image placed in the level, such as a decoration, pickup or enemy
+type Sprite struct {
+	Pos     Pos32
+	Texture string  // Image name, empty for the default texture
+	Size    float32 // Height in wall heights, 0 for DefaultSpriteSize
+	Key     string  // Key the player picks up by walking over the sprite, empty for none
+	Tag     int     // Links the sprite to triggers and scripts
+}
+
+// Height of a sprite that doesn't set one
+const DefaultSpriteSize = 0.5
+
+// DoorMotion is how a door moves as it opens
+type DoorMotion int
+
+const (
+	Slide DoorMotion = iota // Slides along its own length, past its second end
+	Swing                   // Swings round its first end
+)
+
+// Door is a wall that opens and closes
+type Door struct {
+	Wall   Line32 // Closed position, with the attributes it's drawn with
+	Motion DoorMotion
+	Angle  float32 // Degrees a swinging door turns, negative to turn the other way, 0 for DefaultDoorAngle
+	Speed  float32 // Times it can open per second, 0 for DefaultDoorSpeed
+	Wait   float32 // Seconds it stays open after being used, 0 for DefaultDoorWait
+	Key    string  // Key the player needs to open it, empty for none
+	Near   bool    // Whether it opens as the player comes near, rather than with the use key
+}
+
+// Settings of a door that doesn't set them
+const (
+	DefaultDoorAngle = 90
+	DefaultDoorSpeed = 1
+	DefaultDoorWait  = 3
+)
+
+// Heights of a sector that doesn't set them, and of the space outside every sector
+const (
+	DefaultFloor   = 0
+	DefaultCeiling = 1
+)
+
+// Sector is a closed polygon of floor with its own floor and ceiling heights.
+// Heights are in wall heights, so they aren't scaled with the coordinates.
+type Sector struct {
+	Points         []Pos32
+	Holes          [][]Pos32 // Polygons cut out of the sector, such as pillars
+	Floor, Ceiling float32
+	Tag            int // Links the sector to triggers and scripts
+}
+
+// Contains reports whether p is inside the sector's polygon and outside its holes
+func (sec Sector) Contains(p Pos32) bool {
+	if !polygonContains(sec.Points, p) {
+		return false
+	}
+	for _, hole := range sec.Holes {
+		if polygonContains(hole, p) {
+			return false
+		}
+	}
+	return true
+}
+
+func polygonContains(points []Pos32, p Pos32) bool {
+	inside := false
+	for i, a := range points {
+		b := points[(i+1)%len(points)]
+
+		// Count the edges crossed by a ray from p towards +X
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < a.X+(p.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y) {
+			inside = !inside
+		}
+	}
+	return inside
+}
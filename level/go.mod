@@ -0,0 +1,3 @@
+module level
+
+go 1.23.0
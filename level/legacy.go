@@ -0,0 +1,35 @@
+package level
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Parse the original unversioned format.
+// An "x,y" line sets the player start, and each "x1,y1,x2,y2" line is a wall.
+func parseLegacy(lines []string) (Level, error) {
+	lvl := Level{Version: 1}
+
+	for l, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		args := strings.Split(line, ",")
+		if len(args) != 2 && len(args) != 4 {
+			return lvl, fmt.Errorf("line %v: expected 2 or 4 values, got %v", l+1, len(args))
+		}
+
+		coords, err := parseCoords(args)
+		if err != nil {
+			return lvl, fmt.Errorf("line %v: %w", l+1, err)
+		}
+
+		if len(coords) == 2 {
+			lvl.Start = Pos32{X: coords[0], Y: coords[1]}
+			continue
+		}
+		lvl.Walls = append(lvl.Walls, Line32{X1: coords[0], Y1: coords[1], X2: coords[2], Y2: coords[3]})
+	}
+	return lvl, nil
+}
@@ -0,0 +1,617 @@
+// Package level holds the level model shared by the game and the editor,
+// and reads and writes the level file format.
+//
+// A level file starts with a "level <version>" header, followed by one
+// keyword per line. Blank lines and lines starting with # are ignored.
+//
+//	level 7
+//	meta name First floor
+//	start 548,742
+//	wall 412,594,423,779 texture=brick color=#c08040 height=1.5 twosided tag=3
+//	wall 423,779,500,700 texture=grate masked passable
+//	sector 412,594 423,779 500,700 floor=0.25 ceiling=1.5 tag=4
+//	hole 440,700 450,700 450,710
+//	sprite 500,650 texture=barrel size=0.5 key=red tag=5
+//	door 423,779,480,779 swing angle=-90 speed=2 wait=5 key=red near texture=door
+//
+// A hole line cuts a polygon out of the sector before it.
+// Version 3 added sectors, version 4 holes, version 5 sprites, version 6 masked and
+// passable walls and version 7 doors and sprite keys, older files are read the same way.
+// Files without a header are read with the original format, see parseLegacy.
+package level
+
+import (
+	"fmt"
+	"image/color"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ScaleDiv is the number of level file units per game world unit
+const ScaleDiv = 20
+
+// Version is the newest level file version this package reads, and the one it writes
+const Version = 7
+
+// Level is a player start position, a list of walls, the sectors they bound and the sprites and doors in them
+type Level struct {
+	Version int               // Version of the file the level was read from
+	Meta    map[string]string // Free-form level settings such as the name
+	Start   Pos32
+	Walls   []Line32
+	Sectors []Sector
+	Sprites []Sprite
+	Doors   []Door
+}
+
+// Read loads a level file
+func Read(path string) (Level, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Level{}, fmt.Errorf("unable to read %v: %w", path, err)
+	}
+
+	lvl, err := Parse(string(data))
+	if err != nil {
+		return lvl, fmt.Errorf("%v %w", path, err)
+	}
+	return lvl, nil
+}
+
+// Write saves a level file
+func Write(path string, lvl Level) error {
+	return os.WriteFile(path, []byte(lvl.Format()), 0644)
+}
+
+// Parse reads a level file in either the versioned or the legacy format
+func Parse(text string) (Level, error) {
+	lines := strings.Split(text, "\n")
+
+	for l, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if fields[0] != "level" {
+			break
+		}
+		return parseVersioned(lines, l)
+	}
+	return parseLegacy(lines)
+}
+
+// Parse a versioned file whose header is on line number header
+func parseVersioned(lines []string, header int) (Level, error) {
+	lvl := Level{Meta: map[string]string{}}
+
+	fields := strings.Fields(lines[header])
+	if len(fields) != 2 {
+		return lvl, fmt.Errorf("line %v: expected \"level <version>\"", header+1)
+	}
+	version, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return lvl, fmt.Errorf("line %v: bad version: %w", header+1, err)
+	}
+	if version < 2 || version > Version {
+		return lvl, fmt.Errorf("line %v: unsupported level version %v", header+1, version)
+	}
+	lvl.Version = version
+
+	for l := header + 1; l < len(lines); l++ {
+		line := strings.TrimSpace(lines[l])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := parseLine(&lvl, line); err != nil {
+			return lvl, fmt.Errorf("line %v: %w", l+1, err)
+		}
+	}
+	return lvl, nil
+}
+
+// The version each keyword arrived in, files declaring an older version can't use it
+var keywordVersions = map[string]int{
+	"sector": 3,
+	"hole":   4,
+	"sprite": 5,
+	"door":   7,
+}
+
+// The version attributes arrived in after their keyword, by keyword and attribute name
+var attrVersions = map[string]int{
+	"wall masked":   6,
+	"wall passable": 6,
+	"sprite key":    7,
+}
+
+// Parse one keyword line of a versioned file into the level
+func parseLine(lvl *Level, line string) error {
+	fields := strings.Fields(line)
+	if since := keywordVersions[fields[0]]; lvl.Version < since {
+		return fmt.Errorf("%v needs level version %v or newer, the file is version %v", fields[0], since, lvl.Version)
+	}
+	for _, field := range fields[1:] {
+		attr, _, _ := strings.Cut(field, "=")
+		if since := attrVersions[fields[0]+" "+attr]; lvl.Version < since {
+			return fmt.Errorf("%v %v needs level version %v or newer, the file is version %v", fields[0], attr, since, lvl.Version)
+		}
+	}
+
+	switch fields[0] {
+	case "meta":
+		_, rest, _ := strings.Cut(line, "meta")
+		key, value, _ := strings.Cut(strings.TrimSpace(rest), " ")
+		if key == "" {
+			return fmt.Errorf("meta needs a key")
+		}
+		lvl.Meta[key] = strings.TrimSpace(value)
+
+	case "start":
+		if len(fields) != 2 {
+			return fmt.Errorf("expected \"start x,y\"")
+		}
+		coords, err := parseCoords(strings.Split(fields[1], ","))
+		if err != nil {
+			return err
+		}
+		if len(coords) != 2 {
+			return fmt.Errorf("start needs 2 values, got %v", len(coords))
+		}
+		lvl.Start = Pos32{X: coords[0], Y: coords[1]}
+
+	case "wall":
+		if len(fields) < 2 {
+			return fmt.Errorf("expected \"wall x1,y1,x2,y2 [attributes]\"")
+		}
+		coords, err := parseCoords(strings.Split(fields[1], ","))
+		if err != nil {
+			return err
+		}
+		if len(coords) != 4 {
+			return fmt.Errorf("wall needs 4 values, got %v", len(coords))
+		}
+		wall := Line32{X1: coords[0], Y1: coords[1], X2: coords[2], Y2: coords[3]}
+		for _, field := range fields[2:] {
+			if err := parseWallAttr(&wall.Attrs, field); err != nil {
+				return err
+			}
+		}
+		lvl.Walls = append(lvl.Walls, wall)
+
+	case "sector":
+		sec := Sector{Floor: DefaultFloor, Ceiling: DefaultCeiling}
+		for _, field := range fields[1:] {
+			if strings.Contains(field, "=") {
+				if err := parseSectorAttr(&sec, field); err != nil {
+					return err
+				}
+				continue
+			}
+			coords, err := parseCoords(strings.Split(field, ","))
+			if err != nil {
+				return err
+			}
+			if len(coords) != 2 {
+				return fmt.Errorf("sector point needs 2 values, got %v", len(coords))
+			}
+			sec.Points = append(sec.Points, Pos32{X: coords[0], Y: coords[1]})
+		}
+		if len(sec.Points) < 3 {
+			return fmt.Errorf("sector needs at least 3 points, got %v", len(sec.Points))
+		}
+		if sec.Ceiling < sec.Floor {
+			return fmt.Errorf("sector ceiling %v is below its floor %v", sec.Ceiling, sec.Floor)
+		}
+		lvl.Sectors = append(lvl.Sectors, sec)
+
+	case "hole":
+		if len(lvl.Sectors) == 0 {
+			return fmt.Errorf("hole needs a sector before it")
+		}
+		var hole []Pos32
+		for _, field := range fields[1:] {
+			coords, err := parseCoords(strings.Split(field, ","))
+			if err != nil {
+				return err
+			}
+			if len(coords) != 2 {
+				return fmt.Errorf("hole point needs 2 values, got %v", len(coords))
+			}
+			hole = append(hole, Pos32{X: coords[0], Y: coords[1]})
+		}
+		if len(hole) < 3 {
+			return fmt.Errorf("hole needs at least 3 points, got %v", len(hole))
+		}
+		sec := &lvl.Sectors[len(lvl.Sectors)-1]
+		sec.Holes = append(sec.Holes, hole)
+
+	case "sprite":
+		if len(fields) < 2 {
+			return fmt.Errorf("expected \"sprite x,y [attributes]\"")
+		}
+		coords, err := parseCoords(strings.Split(fields[1], ","))
+		if err != nil {
+			return err
+		}
+		if len(coords) != 2 {
+			return fmt.Errorf("sprite needs 2 values, got %v", len(coords))
+		}
+		spr := Sprite{Pos: Pos32{X: coords[0], Y: coords[1]}}
+		for _, field := range fields[2:] {
+			if err := parseSpriteAttr(&spr, field); err != nil {
+				return err
+			}
+		}
+		lvl.Sprites = append(lvl.Sprites, spr)
+
+	case "door":
+		if len(fields) < 2 {
+			return fmt.Errorf("expected \"door x1,y1,x2,y2 [attributes]\"")
+		}
+		coords, err := parseCoords(strings.Split(fields[1], ","))
+		if err != nil {
+			return err
+		}
+		if len(coords) != 4 {
+			return fmt.Errorf("door needs 4 values, got %v", len(coords))
+		}
+		door := Door{Wall: Line32{X1: coords[0], Y1: coords[1], X2: coords[2], Y2: coords[3]}}
+		for _, field := range fields[2:] {
+			if err := parseDoorAttr(&door, field); err != nil {
+				return err
+			}
+		}
+		lvl.Doors = append(lvl.Doors, door)
+
+	default:
+		return fmt.Errorf("unknown keyword %q", fields[0])
+	}
+	return nil
+}
+
+// Parse a single key=value wall attribute
+func parseWallAttr(attrs *WallAttrs, field string) error {
+	key, value, hasValue := strings.Cut(field, "=")
+
+	var err error
+	switch key {
+	case "texture":
+		if value == "" {
+			return fmt.Errorf("texture needs a name")
+		}
+		attrs.Texture = value
+	case "color":
+		attrs.Color, err = parseColor(value)
+	case "height":
+		var height float64
+		height, err = strconv.ParseFloat(value, 32)
+		attrs.Height = float32(height)
+	case "twosided":
+		attrs.TwoSided = true
+		if hasValue {
+			attrs.TwoSided, err = strconv.ParseBool(value)
+		}
+	case "masked":
+		attrs.Masked = true
+		if hasValue {
+			attrs.Masked, err = strconv.ParseBool(value)
+		}
+	case "passable":
+		attrs.Passable = true
+		if hasValue {
+			attrs.Passable, err = strconv.ParseBool(value)
+		}
+	case "tag":
+		attrs.Tag, err = strconv.Atoi(value)
+	default:
+		return fmt.Errorf("unknown wall attribute %q", key)
+	}
+
+	if err != nil {
+		return fmt.Errorf("bad %v: %w", key, err)
+	}
+	return nil
+}
+
+// Parse a single key=value sector attribute
+func parseSectorAttr(sec *Sector, field string) error {
+	key, value, _ := strings.Cut(field, "=")
+
+	var err error
+	var height float64
+	switch key {
+	case "floor":
+		height, err = strconv.ParseFloat(value, 32)
+		sec.Floor = float32(height)
+	case "ceiling":
+		height, err = strconv.ParseFloat(value, 32)
+		sec.Ceiling = float32(height)
+	case "tag":
+		sec.Tag, err = strconv.Atoi(value)
+	default:
+		return fmt.Errorf("unknown sector attribute %q", key)
+	}
+
+	if err != nil {
+		return fmt.Errorf("bad %v: %w", key, err)
+	}
+	return nil
+}
+
+// Parse a single key=value sprite attribute
+func parseSpriteAttr(spr *Sprite, field string) error {
+	key, value, _ := strings.Cut(field, "=")
+
+	var err error
+	switch key {
+	case "texture":
+		if value == "" {
+			return fmt.Errorf("texture needs a name")
+		}
+		spr.Texture = value
+	case "size":
+		var size float64
+		size, err = strconv.ParseFloat(value, 32)
+		if err == nil && size <= 0 {
+			err = fmt.Errorf("must be above 0")
+		}
+		spr.Size = float32(size)
+	case "key":
+		if value == "" {
+			return fmt.Errorf("key needs a name")
+		}
+		spr.Key = value
+	case "tag":
+		spr.Tag, err = strconv.Atoi(value)
+	default:
+		return fmt.Errorf("unknown sprite attribute %q", key)
+	}
+
+	if err != nil {
+		return fmt.Errorf("bad %v: %w", key, err)
+	}
+	return nil
+}
+
+// Parse a single door attribute, falling back to the wall attributes for how it's drawn
+func parseDoorAttr(door *Door, field string) error {
+	key, value, hasValue := strings.Cut(field, "=")
+
+	var err error
+	var number float64
+	switch key {
+	case "slide", "swing":
+		if hasValue {
+			return fmt.Errorf("%v doesn't take a value", key)
+		}
+		door.Motion = Slide
+		if key == "swing" {
+			door.Motion = Swing
+		}
+	case "near":
+		door.Near = true
+		if hasValue {
+			door.Near, err = strconv.ParseBool(value)
+		}
+	case "angle":
+		number, err = strconv.ParseFloat(value, 32)
+		door.Angle = float32(number)
+	case "speed":
+		number, err = strconv.ParseFloat(value, 32)
+		if err == nil && number <= 0 {
+			err = fmt.Errorf("must be above 0")
+		}
+		door.Speed = float32(number)
+	case "wait":
+		number, err = strconv.ParseFloat(value, 32)
+		if err == nil && number <= 0 {
+			err = fmt.Errorf("must be above 0")
+		}
+		door.Wait = float32(number)
+	case "key":
+		if value == "" {
+			return fmt.Errorf("key needs a name")
+		}
+		door.Key = value
+	default:
+		return parseWallAttr(&door.Wall.Attrs, field)
+	}
+
+	if err != nil {
+		return fmt.Errorf("bad %v: %w", key, err)
+	}
+	return nil
+}
+
+// Format writes the current versioned level format
+func (lvl Level) Format() string {
+	var buf strings.Builder
+
+	fmt.Fprintf(&buf, "level %v\n", Version)
+
+	keys := make([]string, 0, len(lvl.Meta))
+	for key := range lvl.Meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "meta %v %v\n", key, lvl.Meta[key])
+	}
+
+	fmt.Fprintf(&buf, "start %v,%v\n", formatCoord(lvl.Start.X), formatCoord(lvl.Start.Y))
+	for _, wall := range lvl.Walls {
+		fmt.Fprintf(&buf, "wall %v,%v,%v,%v%v\n", formatCoord(wall.X1), formatCoord(wall.Y1), formatCoord(wall.X2), formatCoord(wall.Y2), formatWallAttrs(wall.Attrs))
+	}
+	for _, sec := range lvl.Sectors {
+		buf.WriteString("sector")
+		for _, p := range sec.Points {
+			fmt.Fprintf(&buf, " %v,%v", formatCoord(p.X), formatCoord(p.Y))
+		}
+		fmt.Fprintf(&buf, " floor=%v ceiling=%v", formatCoord(sec.Floor), formatCoord(sec.Ceiling))
+		if sec.Tag != 0 {
+			fmt.Fprintf(&buf, " tag=%v", sec.Tag)
+		}
+		buf.WriteString("\n")
+
+		for _, hole := range sec.Holes {
+			buf.WriteString("hole")
+			for _, p := range hole {
+				fmt.Fprintf(&buf, " %v,%v", formatCoord(p.X), formatCoord(p.Y))
+			}
+			buf.WriteString("\n")
+		}
+	}
+	for _, spr := range lvl.Sprites {
+		fmt.Fprintf(&buf, "sprite %v,%v", formatCoord(spr.Pos.X), formatCoord(spr.Pos.Y))
+		if spr.Texture != "" {
+			buf.WriteString(" texture=" + spr.Texture)
+		}
+		if spr.Size != 0 {
+			buf.WriteString(" size=" + formatCoord(spr.Size))
+		}
+		if spr.Key != "" {
+			buf.WriteString(" key=" + spr.Key)
+		}
+		if spr.Tag != 0 {
+			fmt.Fprintf(&buf, " tag=%v", spr.Tag)
+		}
+		buf.WriteString("\n")
+	}
+	for _, door := range lvl.Doors {
+		wall := door.Wall
+		fmt.Fprintf(&buf, "door %v,%v,%v,%v", formatCoord(wall.X1), formatCoord(wall.Y1), formatCoord(wall.X2), formatCoord(wall.Y2))
+		if door.Motion == Swing {
+			buf.WriteString(" swing")
+		}
+		if door.Angle != 0 {
+			buf.WriteString(" angle=" + formatCoord(door.Angle))
+		}
+		if door.Speed != 0 {
+			buf.WriteString(" speed=" + formatCoord(door.Speed))
+		}
+		if door.Wait != 0 {
+			buf.WriteString(" wait=" + formatCoord(door.Wait))
+		}
+		if door.Key != "" {
+			buf.WriteString(" key=" + door.Key)
+		}
+		if door.Near {
+			buf.WriteString(" near")
+		}
+		buf.WriteString(formatWallAttrs(wall.Attrs) + "\n")
+	}
+	return buf.String()
+}
+
+// Write the non-default wall attributes, each with a leading space
+func formatWallAttrs(attrs WallAttrs) string {
+	buf := ""
+	if attrs.Texture != "" {
+		buf += " texture=" + attrs.Texture
+	}
+	if attrs.Color != (color.NRGBA{}) {
+		buf += " color=" + formatColor(attrs.Color)
+	}
+	if attrs.Height != 0 {
+		buf += " height=" + formatCoord(attrs.Height)
+	}
+	if attrs.TwoSided {
+		buf += " twosided"
+	}
+	if attrs.Masked {
+		buf += " masked"
+	}
+	if attrs.Passable {
+		buf += " passable"
+	}
+	if attrs.Tag != 0 {
+		buf += " tag=" + strconv.Itoa(attrs.Tag)
+	}
+	return buf
+}
+
+// Scaled returns a copy of the level with every coordinate divided by div.
+// Wall and sector heights, sprite sizes and door speeds are left alone.
+func (lvl Level) Scaled(div float32) Level {
+	scaled := lvl
+	scaled.Start = Pos32{X: lvl.Start.X / div, Y: lvl.Start.Y / div}
+	scaled.Walls = make([]Line32, len(lvl.Walls))
+	for i, wall := range lvl.Walls {
+		wall.X1, wall.Y1, wall.X2, wall.Y2 = wall.X1/div, wall.Y1/div, wall.X2/div, wall.Y2/div
+		wall.Offset /= div
+		scaled.Walls[i] = wall
+	}
+	scaled.Sectors = make([]Sector, len(lvl.Sectors))
+	for i, sec := range lvl.Sectors {
+		sec.Points = scalePoints(sec.Points, div)
+		sec.Holes = nil
+		for _, hole := range lvl.Sectors[i].Holes {
+			sec.Holes = append(sec.Holes, scalePoints(hole, div))
+		}
+		scaled.Sectors[i] = sec
+	}
+	scaled.Sprites = make([]Sprite, len(lvl.Sprites))
+	for i, spr := range lvl.Sprites {
+		spr.Pos = Pos32{X: spr.Pos.X / div, Y: spr.Pos.Y / div}
+		scaled.Sprites[i] = spr
+	}
+	scaled.Doors = make([]Door, len(lvl.Doors))
+	for i, door := range lvl.Doors {
+		wall := &door.Wall
+		wall.X1, wall.Y1, wall.X2, wall.Y2 = wall.X1/div, wall.Y1/div, wall.X2/div, wall.Y2/div
+		wall.Offset /= div
+		scaled.Doors[i] = door
+	}
+	return scaled
+}
+
+func scalePoints(points []Pos32, div float32) []Pos32 {
+	scaled := make([]Pos32, len(points))
+	for i, p := range points {
+		scaled[i] = Pos32{X: p.X / div, Y: p.Y / div}
+	}
+	return scaled
+}
+
+func parseCoords(args []string) ([]float32, error) {
+	coords := make([]float32, len(args))
+	for i, arg := range args {
+		val, err := strconv.ParseFloat(strings.TrimSpace(arg), 32)
+		if err != nil {
+			return nil, err
+		}
+		coords[i] = float32(val)
+	}
+	return coords, nil
+}
+
+// Shortest text that reads back as exactly the same float32
+func formatCoord(val float32) string {
+	return strconv.FormatFloat(float64(val), 'g', -1, 32)
+}
+
+// Parse a #rrggbb or #rrggbbaa colour
+func parseColor(text string) (color.NRGBA, error) {
+	hex, ok := strings.CutPrefix(text, "#")
+	if !ok || (len(hex) != 6 && len(hex) != 8) {
+		return color.NRGBA{}, fmt.Errorf("expected #rrggbb or #rrggbbaa, got %q", text)
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+
+	val, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, err
+	}
+	return color.NRGBA{R: uint8(val >> 24), G: uint8(val >> 16), B: uint8(val >> 8), A: uint8(val)}, nil
+}
+
+func formatColor(c color.NRGBA) string {
+	if c.A == 255 {
+		return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+}
@@ -0,0 +1,329 @@
+package level
+
+import (
+	"image/color"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormatParseRoundTrip(t *testing.T) {
+	want := Level{
+		Meta:  map[string]string{"name": "First floor", "author": "someone"},
+		Start: Pos32{X: 548, Y: 742.5},
+		Walls: []Line32{
+			{X1: 412, Y1: 594, X2: 423, Y2: 779},
+			{X1: 0.1, Y1: 1.0 / 3, X2: -17.25, Y2: 123456.79},
+			{X1: 1e-7, Y1: 3e9, X2: 2, Y2: 2},
+			{X1: 1, Y1: 2, X2: 3, Y2: 4, Attrs: WallAttrs{
+				Texture: "brick", Color: color.NRGBA{R: 192, G: 128, B: 64, A: 255}, Height: 1.5, TwoSided: true, Tag: 3,
+			}},
+			{X1: 3, Y1: 4, X2: 5, Y2: 6, Attrs: WallAttrs{Texture: "grate", Masked: true, Passable: true}},
+			{X1: 1, Y1: 2, X2: 3, Y2: 4, Attrs: WallAttrs{Color: color.NRGBA{R: 1, G: 2, B: 3, A: 4}}},
+		},
+		Sectors: []Sector{
+			{Points: []Pos32{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}}, Floor: DefaultFloor, Ceiling: DefaultCeiling},
+			{Points: []Pos32{{X: 0.5, Y: 1}, {X: 2, Y: 3}, {X: 4, Y: 5}, {X: 6, Y: 1}}, Floor: -0.25, Ceiling: 1.75, Tag: 7},
+			{Points: []Pos32{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}}, Floor: DefaultFloor, Ceiling: DefaultCeiling, Holes: [][]Pos32{
+				{{X: 2, Y: 2}, {X: 3, Y: 2}, {X: 3, Y: 3}},
+				{{X: 5, Y: 5}, {X: 6, Y: 5}, {X: 6, Y: 6}, {X: 5, Y: 6}},
+			}},
+		},
+		Sprites: []Sprite{
+			{Pos: Pos32{X: 5, Y: 7.5}},
+			{Pos: Pos32{X: -2, Y: 1.0 / 3}, Texture: "barrel", Size: 0.75, Key: "red", Tag: 9},
+		},
+		Doors: []Door{
+			{Wall: Line32{X1: 1, Y1: 2, X2: 3, Y2: 2}},
+			{Wall: Line32{X1: 0, Y1: 0.5, X2: 0, Y2: 2, Attrs: WallAttrs{Texture: "door", Tag: 4}},
+				Motion: Swing, Angle: -90, Speed: 2, Wait: 5.5, Key: "blue", Near: true},
+		},
+	}
+
+	got, err := Parse(want.Format())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != Version {
+		t.Errorf("version = %v, want %v", got.Version, Version)
+	}
+	for key, value := range want.Meta {
+		if got.Meta[key] != value {
+			t.Errorf("meta %v = %q, want %q", key, got.Meta[key], value)
+		}
+	}
+	if got.Start != want.Start {
+		t.Errorf("start = %v, want %v", got.Start, want.Start)
+	}
+	if len(got.Walls) != len(want.Walls) {
+		t.Fatalf("got %v walls, want %v", len(got.Walls), len(want.Walls))
+	}
+	for i := range want.Walls {
+		if got.Walls[i] != want.Walls[i] {
+			t.Errorf("wall %v = %v, want %v", i, got.Walls[i], want.Walls[i])
+		}
+	}
+	if !reflect.DeepEqual(got.Sectors, want.Sectors) {
+		t.Errorf("sectors = %v, want %v", got.Sectors, want.Sectors)
+	}
+	if !reflect.DeepEqual(got.Sprites, want.Sprites) {
+		t.Errorf("sprites = %v, want %v", got.Sprites, want.Sprites)
+	}
+	if !reflect.DeepEqual(got.Doors, want.Doors) {
+		t.Errorf("doors = %v, want %v", got.Doors, want.Doors)
+	}
+}
+
+// The editor saves in file units and the game loads them scaled into world units
+func TestEditorSaveGameLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "level.txt")
+	saved := Level{
+		Start: Pos32{X: 548, Y: 742},
+		Walls: []Line32{
+			{X1: 412, Y1: 594, X2: 423, Y2: 779},
+			{X1: 630.5, Y1: 616.25, X2: 571.125, Y2: 615},
+		},
+	}
+
+	if err := Write(path, saved); err != nil {
+		t.Fatal(err)
+	}
+
+	// Editor reload
+	edited, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.Start != saved.Start || len(edited.Walls) != len(saved.Walls) {
+		t.Fatalf("editor read %v, want %v", edited, saved)
+	}
+	for i := range saved.Walls {
+		if edited.Walls[i] != saved.Walls[i] {
+			t.Errorf("editor wall %v = %v, want %v", i, edited.Walls[i], saved.Walls[i])
+		}
+	}
+
+	// Game load
+	world := edited.Scaled(ScaleDiv)
+	if want := (Pos32{X: saved.Start.X / ScaleDiv, Y: saved.Start.Y / ScaleDiv}); world.Start != want {
+		t.Errorf("game start = %v, want %v", world.Start, want)
+	}
+	for i, wall := range saved.Walls {
+		want := Line32{X1: wall.X1 / ScaleDiv, Y1: wall.Y1 / ScaleDiv, X2: wall.X2 / ScaleDiv, Y2: wall.Y2 / ScaleDiv}
+		if world.Walls[i] != want {
+			t.Errorf("game wall %v = %v, want %v", i, world.Walls[i], want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		wantStart Pos32
+		wantWalls int
+		wantErr   string
+	}{
+		{"legacy", "10,20\n1,2,3,4\n5,6,7,8\n", Pos32{X: 10, Y: 20}, 2, ""},
+		{"legacy no start line", "1,2,3,4\n5,6,7,8", Pos32{}, 2, ""},
+		{"legacy windows line endings", "10,20\r\n1,2,3,4\r\n", Pos32{X: 10, Y: 20}, 1, ""},
+		{"legacy blank lines", "\n10,20\n\n1,2,3,4\n\n", Pos32{X: 10, Y: 20}, 1, ""},
+		{"legacy bad number", "10,20\n1,2,x,4\n", Pos32{}, 0, "line 2:"},
+		{"legacy wrong count", "10,20\n1,2,3\n", Pos32{}, 0, "line 2:"},
+		{"versioned", "level 2\nstart 10,20\nwall 1,2,3,4\n", Pos32{X: 10, Y: 20}, 1, ""},
+		{"versioned comments", "# made by hand\nlevel 2\n\n# walls\nwall 1,2,3,4 tag=1\r\n", Pos32{}, 1, ""},
+		{"older version", "level 2\nwall 1,2,3,4\n", Pos32{}, 1, ""},
+		{"newer version", "level 8\nwall 1,2,3,4\n", Pos32{}, 0, "line 1:"},
+		{"bad version", "level two\n", Pos32{}, 0, "line 1:"},
+		{"unknown keyword", "level 2\nstart 1,2\nactor 1,2\n", Pos32{}, 0, "line 3:"},
+		{"unknown attribute", "level 2\nwall 1,2,3,4 shiny\n", Pos32{}, 0, "line 2:"},
+		{"bad wall", "level 2\nwall 1,2,3\n", Pos32{}, 0, "line 2:"},
+		{"bad color", "level 2\nwall 1,2,3,4 color=red\n", Pos32{}, 0, "line 2:"},
+		{"bad height", "level 2\n\nwall 1,2,3,4 height=tall\n", Pos32{}, 0, "line 3:"},
+		{"sector", "level 3\nsector 0,0 1,0 1,1 floor=0.5\n", Pos32{}, 0, ""},
+		{"sector before version 3", "level 2\nsector 0,0 1,0 1,1\n", Pos32{}, 0, "line 2:"},
+		{"sector too few points", "level 3\nsector 0,0 1,0 floor=0.5\n", Pos32{}, 0, "line 2:"},
+		{"sector bad point", "level 3\nsector 0,0 1,0,2 1,1\n", Pos32{}, 0, "line 2:"},
+		{"sector unknown attribute", "level 3\nsector 0,0 1,0 1,1 sky=blue\n", Pos32{}, 0, "line 2:"},
+		{"sector ceiling below floor", "level 3\nsector 0,0 1,0 1,1 floor=2 ceiling=1\n", Pos32{}, 0, "line 2:"},
+		{"hole", "level 4\nsector 0,0 4,0 4,4\nhole 1,1 2,1 2,2\n", Pos32{}, 0, ""},
+		{"hole without sector", "level 4\nhole 1,1 2,1 2,2\n", Pos32{}, 0, "line 2:"},
+		{"hole too few points", "level 4\nsector 0,0 4,0 4,4\nhole 1,1 2,1\n", Pos32{}, 0, "line 3:"},
+		{"sprite", "level 5\nsprite 1,2 texture=barrel size=0.5 tag=1\n", Pos32{}, 0, ""},
+		{"sprite bad point", "level 5\nsprite 1,2,3\n", Pos32{}, 0, "line 2:"},
+		{"sprite unknown attribute", "level 5\nsprite 1,2 solid\n", Pos32{}, 0, "line 2:"},
+		{"sprite zero size", "level 5\nsprite 1,2 size=0\n", Pos32{}, 0, "line 2:"},
+		{"hole before version 4", "level 3\nsector 0,0 4,0 4,4\nhole 1,1 2,1 2,2\n", Pos32{}, 0, "line 3:"},
+		{"sprite before version 5", "level 4\nsprite 1,2\n", Pos32{}, 0, "line 2:"},
+		{"masked wall before version 6", "level 5\nwall 1,2,3,4 masked\n", Pos32{}, 0, "line 2:"},
+		{"passable wall before version 6", "level 5\nwall 1,2,3,4 passable=false\n", Pos32{}, 0, "line 2:"},
+		{"sprite key before version 7", "level 6\nsprite 1,2 key=red\n", Pos32{}, 0, "line 2:"},
+		{"door before version 7", "level 6\ndoor 1,2,3,4\n", Pos32{}, 0, "line 2:"},
+		{"door", "level 7\ndoor 1,2,3,4 swing speed=2 key=red near texture=door\n", Pos32{}, 0, ""},
+		{"door bad wall", "level 7\ndoor 1,2,3\n", Pos32{}, 0, "line 2:"},
+		{"door unknown attribute", "level 7\ndoor 1,2,3,4 locked\n", Pos32{}, 0, "line 2:"},
+		{"door bad near", "level 7\ndoor 1,2,3,4 near=maybe\n", Pos32{}, 0, "line 2:"},
+		{"door slide with value", "level 7\ndoor 1,2,3,4 slide=false\n", Pos32{}, 0, "line 2:"},
+		{"door swing with value", "level 7\ndoor 1,2,3,4 swing=1\n", Pos32{}, 0, "line 2:"},
+		{"door zero speed", "level 7\ndoor 1,2,3,4 speed=0\n", Pos32{}, 0, "line 2:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lvl, err := Parse(tt.text)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lvl.Start != tt.wantStart {
+				t.Errorf("start = %v, want %v", lvl.Start, tt.wantStart)
+			}
+			if len(lvl.Walls) != tt.wantWalls {
+				t.Errorf("got %v walls, want %v", len(lvl.Walls), tt.wantWalls)
+			}
+		})
+	}
+}
+
+func TestParseWallAttrs(t *testing.T) {
+	lvl, err := Parse("level 6\nwall 1,2,3,4 texture=grate color=#10203040 height=0.5 twosided=false masked passable=false tag=12\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := WallAttrs{Texture: "grate", Color: color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0x40}, Height: 0.5, Masked: true, Tag: 12}
+	if lvl.Walls[0].Attrs != want {
+		t.Errorf("attributes = %+v, want %+v", lvl.Walls[0].Attrs, want)
+	}
+}
+
+func TestParseDoorAttrs(t *testing.T) {
+	lvl, err := Parse("level 7\ndoor 1,2,3,4 swing near=false key=red\ndoor 1,2,3,4 near=true\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if door := lvl.Doors[0]; door.Motion != Swing || door.Near || door.Key != "red" {
+		t.Errorf("first door = %+v, want a swinging door opened with the red key", door)
+	}
+	if !lvl.Doors[1].Near {
+		t.Error("near=true door doesn't open when the player is near")
+	}
+}
+
+func TestParseSectorDefaults(t *testing.T) {
+	lvl, err := Parse("level 3\nsector 0,0 4,0 4,4 0,4 ceiling=2\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sec := lvl.Sectors[0]
+	if sec.Floor != DefaultFloor || sec.Ceiling != 2 || len(sec.Points) != 4 {
+		t.Errorf("sector = %+v, want 4 points, floor %v and ceiling 2", sec, DefaultFloor)
+	}
+}
+
+func TestSectorContains(t *testing.T) {
+	// An L shape, so the notch is outside even though it's inside the bounding box
+	sec := Sector{Points: []Pos32{{X: 0, Y: 0}, {X: 4, Y: 0}, {X: 4, Y: 2}, {X: 2, Y: 2}, {X: 2, Y: 4}, {X: 0, Y: 4}}}
+
+	tests := []struct {
+		p    Pos32
+		want bool
+	}{
+		{Pos32{X: 1, Y: 1}, true},
+		{Pos32{X: 3, Y: 1}, true},
+		{Pos32{X: 1, Y: 3}, true},
+		{Pos32{X: 3, Y: 3}, false},
+		{Pos32{X: -1, Y: 1}, false},
+		{Pos32{X: 5, Y: 5}, false},
+	}
+	for _, tt := range tests {
+		if got := sec.Contains(tt.p); got != tt.want {
+			t.Errorf("Contains(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestSectorContainsHoles(t *testing.T) {
+	sec := Sector{
+		Points: []Pos32{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}},
+		Holes:  [][]Pos32{{{X: 4, Y: 4}, {X: 6, Y: 4}, {X: 6, Y: 6}, {X: 4, Y: 6}}},
+	}
+
+	if !sec.Contains(Pos32{X: 2, Y: 2}) {
+		t.Error("point beside the hole isn't in the sector")
+	}
+	if sec.Contains(Pos32{X: 5, Y: 5}) {
+		t.Error("point in the hole is in the sector")
+	}
+}
+
+func TestScaledSectors(t *testing.T) {
+	lvl := Level{Sectors: []Sector{{
+		Points: []Pos32{{X: 20, Y: 40}, {X: 60, Y: 0}, {X: 0, Y: 0}},
+		Holes:  [][]Pos32{{{X: 20, Y: 20}, {X: 40, Y: 20}, {X: 20, Y: 0}}},
+		Floor:  0.5, Ceiling: 2,
+	}}}
+	scaled := lvl.Scaled(ScaleDiv)
+
+	want := Sector{Points: []Pos32{{X: 1, Y: 2}, {X: 3, Y: 0}, {X: 0, Y: 0}}, Holes: [][]Pos32{{{X: 1, Y: 1}, {X: 2, Y: 1}, {X: 1, Y: 0}}}, Floor: 0.5, Ceiling: 2}
+	if !reflect.DeepEqual(scaled.Sectors[0], want) {
+		t.Errorf("scaled sector = %v, want %v", scaled.Sectors[0], want)
+	}
+	if lvl.Sectors[0].Points[0].X != 20 || lvl.Sectors[0].Holes[0][0].X != 20 {
+		t.Error("scaling changed the original level")
+	}
+}
+
+func TestScaledSprites(t *testing.T) {
+	lvl := Level{Sprites: []Sprite{{Pos: Pos32{X: 20, Y: 60}, Texture: "barrel", Size: 0.75}}}
+	scaled := lvl.Scaled(ScaleDiv)
+
+	want := Sprite{Pos: Pos32{X: 1, Y: 3}, Texture: "barrel", Size: 0.75}
+	if scaled.Sprites[0] != want {
+		t.Errorf("scaled sprite = %v, want %v", scaled.Sprites[0], want)
+	}
+	if lvl.Sprites[0].Pos.X != 20 {
+		t.Error("scaling changed the original level")
+	}
+}
+
+func TestScaledDoors(t *testing.T) {
+	lvl := Level{Doors: []Door{{Wall: Line32{X1: 20, Y1: 40, X2: 60, Y2: 40}, Speed: 2}}}
+	scaled := lvl.Scaled(ScaleDiv)
+
+	want := Door{Wall: Line32{X1: 1, Y1: 2, X2: 3, Y2: 2}, Speed: 2}
+	if scaled.Doors[0] != want {
+		t.Errorf("scaled door = %v, want %v", scaled.Doors[0], want)
+	}
+	if lvl.Doors[0].Wall.X1 != 20 {
+		t.Error("scaling changed the original level")
+	}
+}
+
+func TestReadLevelFiles(t *testing.T) {
+	tests := []struct {
+		path      string
+		wantWalls int
+	}{
+		{"../level1.txt", 132},
+		{"../level2.txt", 13},
+	}
+
+	for _, tt := range tests {
+		lvl, err := Read(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lvl.Walls) != tt.wantWalls {
+			t.Errorf("%v: got %v walls, want %v", tt.path, len(lvl.Walls), tt.wantWalls)
+		}
+	}
+}